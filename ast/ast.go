@@ -32,6 +32,27 @@ type (
 		Name    string    // identifier name
 	}
 
+	// A VarExpr node represents a reference to a variable.
+	VarExpr struct {
+		Dollar token.Pos // position of "$"
+		Name   string    // variable name
+		Braced bool      // whether the name is enclosed in braces; e.g. ${HOME}
+	}
+
+	// A SubstExpr node represents a command substitution, e.g.
+	// $(git ls-files -m).
+	SubstExpr struct {
+		Dollar token.Pos // position of "$"
+		Stmts  []Stmt    // substituted statements
+		Rparen token.Pos // position of ")"
+	}
+
+	// A WordExpr node represents a word made of adjacent parts, e.g.
+	// $GOPATH/bin.
+	WordExpr struct {
+		Parts []Expr // *Ident, *VarExpr or *SubstExpr
+	}
+
 	BasicLit struct {
 		ValuePos token.Pos   // literal position
 		Kind     token.Token // token.STRING
@@ -44,32 +65,63 @@ type (
 		Rparen token.Pos // position of ")"
 	}
 
+	// A HereDoc node represents a here-document.
+	HereDoc struct {
+		DelimPos token.Pos // position of the delimiter
+		Delim    string    // delimiter as written; e.g. EOF or 'EOF'
+		Body     string    // lines between the operator and the delimiter
+	}
+
 	// A UnaryExpr node represents a unary expression.
 	// Unary "*" expressions are represented via StarExpr nodes.
 	//
 	UnaryExpr struct {
 		OpPos token.Pos   // position of Op
 		Op    token.Token // operator
-		X     Expr        // operand
+		X     Expr        // operand; or nil for token.ERRTOOUT
 	}
 )
 
 func (x *BadExpr) Pos() token.Pos   { return x.From }
 func (x *Ident) Pos() token.Pos     { return x.NamePos }
+func (x *VarExpr) Pos() token.Pos   { return x.Dollar }
+func (x *SubstExpr) Pos() token.Pos { return x.Dollar }
+func (x *WordExpr) Pos() token.Pos  { return x.Parts[0].Pos() }
 func (x *BasicLit) Pos() token.Pos  { return x.ValuePos }
 func (x *ParenExpr) Pos() token.Pos { return x.Lparen }
+func (x *HereDoc) Pos() token.Pos   { return x.DelimPos }
 func (x *UnaryExpr) Pos() token.Pos { return x.OpPos }
 
 func (x *BadExpr) End() token.Pos   { return x.To }
 func (x *Ident) End() token.Pos     { return token.Pos(int(x.NamePos) + len(x.Name)) }
+func (x *SubstExpr) End() token.Pos { return x.Rparen + 1 }
+func (x *WordExpr) End() token.Pos  { return x.Parts[len(x.Parts)-1].End() }
 func (x *BasicLit) End() token.Pos  { return token.Pos(int(x.ValuePos) + len(x.Value)) }
 func (x *ParenExpr) End() token.Pos { return x.Rparen + 1 }
-func (x *UnaryExpr) End() token.Pos { return x.X.End() }
+func (x *HereDoc) End() token.Pos   { return token.Pos(int(x.DelimPos) + len(x.Delim)) }
+
+func (x *UnaryExpr) End() token.Pos {
+	if x.X == nil {
+		return token.Pos(int(x.OpPos) + len(x.Op.String()))
+	}
+	return x.X.End()
+}
+
+func (x *VarExpr) End() token.Pos {
+	if x.Braced {
+		return token.Pos(int(x.Dollar) + len(x.Name) + 3)
+	}
+	return token.Pos(int(x.Dollar) + len(x.Name) + 1)
+}
 
 func (*BadExpr) exprNode()   {}
 func (*Ident) exprNode()     {}
+func (*VarExpr) exprNode()   {}
+func (*SubstExpr) exprNode() {}
+func (*WordExpr) exprNode()  {}
 func (*BasicLit) exprNode()  {}
 func (*ParenExpr) exprNode() {}
+func (*HereDoc) exprNode()   {}
 func (*UnaryExpr) exprNode() {}
 
 func (id *Ident) String() string {
@@ -89,25 +141,141 @@ type (
 	}
 
 	ExecStmt struct {
-		Args []Expr
+		Args   []Expr
+		Redirs []*UnaryExpr // redirections in order of appearance
 	}
 
 	PipeStmt struct {
 		Args []*ExecStmt
 	}
+
+	// An AndOrStmt node represents a conditional execution of Y,
+	// depending on the exit status of X.
+	AndOrStmt struct {
+		X     Stmt        // left operand
+		OpPos token.Pos   // position of Op
+		Op    token.Token // token.LAND or token.LOR
+		Y     Stmt        // right operand
+	}
+
+	// A BgStmt node represents a statement run in the background.
+	BgStmt struct {
+		X   Stmt      // *ExecStmt or *PipeStmt
+		Amp token.Pos // position of "&"
+	}
+
+	// An AssignStmt node represents an assignment to a shell variable,
+	// e.g. x=a or xs=(a b c).
+	AssignStmt struct {
+		Name  *Ident // variable name
+		Value Expr   // value; or nil for the empty string
+	}
+
+	// A FuncStmt node represents a function definition.
+	FuncStmt struct {
+		Func token.Pos // position of "func" keyword
+		Name *Ident    // function name
+		Body *BlockStmt
+	}
+
+	// A BlockStmt node represents a braced statement list.
+	BlockStmt struct {
+		Lbrace token.Pos // position of "{"
+		List   []Stmt
+		Rbrace token.Pos // position of "}"
+	}
+
+	// An IfStmt node represents an if statement, which executes Body if
+	// Cond exits with status 0.
+	IfStmt struct {
+		If   token.Pos // position of "if" keyword
+		Cond Stmt      // condition
+		Body *BlockStmt
+		Else Stmt // else branch; or nil
+	}
+
+	// A WhileStmt node represents a while statement, which repeats Body
+	// while Cond exits with status 0.
+	WhileStmt struct {
+		While token.Pos // position of "while" keyword
+		Cond  Stmt      // condition
+		Body  *BlockStmt
+	}
+
+	// A ForStmt node represents a for statement, which executes Body for
+	// each word of List, assigned to the variable Var.
+	ForStmt struct {
+		For  token.Pos // position of "for" keyword
+		Var  *Ident    // loop variable
+		List Expr      // *ParenExpr, or a word such as $xs
+		Body *BlockStmt
+	}
 )
 
-func (s *BadStmt) Pos() token.Pos  { return s.From }
-func (s *ExecStmt) Pos() token.Pos { return s.Args[0].Pos() }
-func (s *PipeStmt) Pos() token.Pos { return s.Args[0].Pos() }
+func (s *BadStmt) Pos() token.Pos    { return s.From }
+func (s *ExecStmt) Pos() token.Pos   { return s.span()[0].Pos() }
+func (s *PipeStmt) Pos() token.Pos   { return s.Args[0].Pos() }
+func (s *AndOrStmt) Pos() token.Pos  { return s.X.Pos() }
+func (s *BgStmt) Pos() token.Pos     { return s.X.Pos() }
+func (s *AssignStmt) Pos() token.Pos { return s.Name.Pos() }
+func (s *FuncStmt) Pos() token.Pos   { return s.Func }
+func (s *BlockStmt) Pos() token.Pos  { return s.Lbrace }
+func (s *IfStmt) Pos() token.Pos     { return s.If }
+func (s *WhileStmt) Pos() token.Pos  { return s.While }
+func (s *ForStmt) Pos() token.Pos    { return s.For }
 
-func (s *BadStmt) End() token.Pos  { return s.To }
-func (s *ExecStmt) End() token.Pos { return s.Args[len(s.Args)-1].End() }
-func (s *PipeStmt) End() token.Pos { return s.Args[len(s.Args)-1].End() }
+func (s *BadStmt) End() token.Pos   { return s.To }
+func (s *ExecStmt) End() token.Pos  { return s.span()[1].End() }
+func (s *PipeStmt) End() token.Pos  { return s.Args[len(s.Args)-1].End() }
+func (s *AndOrStmt) End() token.Pos { return s.Y.End() }
+func (s *BgStmt) End() token.Pos    { return s.Amp + 1 }
+func (s *FuncStmt) End() token.Pos  { return s.Body.End() }
+func (s *BlockStmt) End() token.Pos { return s.Rbrace + 1 }
+func (s *WhileStmt) End() token.Pos { return s.Body.End() }
+func (s *ForStmt) End() token.Pos   { return s.Body.End() }
+
+func (s *AssignStmt) End() token.Pos {
+	if s.Value != nil {
+		return s.Value.End()
+	}
+	return s.Name.End() + 1
+}
+
+func (s *IfStmt) End() token.Pos {
+	if s.Else != nil {
+		return s.Else.End()
+	}
+	return s.Body.End()
+}
+
+// span returns the first and the last node of s.
+func (s *ExecStmt) span() [2]Node {
+	var first, last Node
+	if len(s.Args) > 0 {
+		first, last = s.Args[0], s.Args[len(s.Args)-1]
+	}
+	if n := len(s.Redirs); n > 0 {
+		if first == nil || s.Redirs[0].Pos() < first.Pos() {
+			first = s.Redirs[0]
+		}
+		if last == nil || s.Redirs[n-1].End() > last.End() {
+			last = s.Redirs[n-1]
+		}
+	}
+	return [2]Node{first, last}
+}
 
-func (*BadStmt) stmtNode()  {}
-func (*ExecStmt) stmtNode() {}
-func (*PipeStmt) stmtNode() {}
+func (*BadStmt) stmtNode()    {}
+func (*ExecStmt) stmtNode()   {}
+func (*PipeStmt) stmtNode()   {}
+func (*AndOrStmt) stmtNode()  {}
+func (*BgStmt) stmtNode()     {}
+func (*AssignStmt) stmtNode() {}
+func (*FuncStmt) stmtNode()   {}
+func (*BlockStmt) stmtNode()  {}
+func (*IfStmt) stmtNode()     {}
+func (*WhileStmt) stmtNode()  {}
+func (*ForStmt) stmtNode()    {}
 
 type File struct {
 	Name  *Ident // package name
@@ -16,11 +16,10 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/elpinal/coco3/config"
-	"github.com/elpinal/coco3/eval"
 	"github.com/elpinal/coco3/gate"
 	"github.com/elpinal/coco3/parser"
+	"github.com/elpinal/coco3/session"
 
-	"github.com/elpinal/coco3/extra"
 	eparser "github.com/elpinal/coco3/extra/parser"
 
 	"github.com/jmoiron/sqlx"
@@ -36,7 +35,15 @@ type CLI struct {
 
 	DB *sqlx.DB
 
-	execute1 func([]byte) (action, error)
+	execute1 func(name string, src []byte) (action, error)
+
+	// incomplete reports whether the interactive input continues on the
+	// next line; or nil.
+	incomplete func([]rune) bool
+
+	// Session holds the state which lasts across the commands executed,
+	// e.g. variables and functions. If nil, a new session is used.
+	Session *session.Session
 }
 
 func (c *CLI) init() {
@@ -50,6 +57,14 @@ func (c *CLI) init() {
 		c.Config = &config.Config{}
 	}
 	c.Config.Init()
+	if c.Session == nil {
+		c.Session = session.New(c.In, c.Out, c.Err, c.DB)
+	}
+	if c.Session.Report == nil {
+		c.Session.Report = func(err error) {
+			c.printExecError(c.locate(err, nil))
+		}
+	}
 }
 
 func (c *CLI) Run(args []string) int {
@@ -65,16 +80,49 @@ func (c *CLI) Run(args []string) int {
 
 	flagC := f.String("c", "", "take first argument as a command to execute")
 	flagE := f.Bool("extra", c.Config.Extra, "switch to extra mode")
+	flagConfig := f.String("config", "", "read configuration from `file` instead of "+config.DefaultFile())
 	if err := f.Parse(args); err != nil {
 		return 2
 	}
+	if err := c.loadConfig(*flagConfig); err != nil {
+		c.errorln(err)
+		return 2
+	}
+	if !isFlagSet(f, "extra") {
+		*flagE = c.Config.Extra
+	}
 	return c.run(f.Args(), flagC, flagE)
 }
 
+// loadConfig reads the configuration file into c.Config.
+// If filename is empty, the default configuration file is read if it exists.
+func (c *CLI) loadConfig(filename string) error {
+	if filename == "" {
+		filename = config.DefaultFile()
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	return c.Config.Load(filename)
+}
+
+func isFlagSet(f *flag.FlagSet, name string) bool {
+	var found bool
+	f.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
 func (c *CLI) run(args []string, flagC *string, flagE *bool) int {
 	// Aliases, only available for non-extra mode.
 	for _, alias := range c.Config.Alias {
-		eval.DefAlias(alias[0], alias[1])
+		if err := c.Session.Aliases().Define(alias[0], alias[1], "config"); err != nil {
+			c.errorln(err)
+			return 1
+		}
 	}
 
 	// Prepare environment.
@@ -93,13 +141,16 @@ func (c *CLI) run(args []string, flagC *string, flagE *bool) int {
 		c.execute1 = c.executeExtra
 	} else {
 		c.execute1 = c.execute
+		c.incomplete = func(r []rune) bool {
+			return parser.Incomplete([]byte(string(r)))
+		}
 	}
 
 	if len(c.Config.StartUpCommand) > 0 {
-		a, err := c.execute1(c.Config.StartUpCommand)
+		a, err := c.execute1("startup", c.Config.StartUpCommand)
 		if err != nil {
 			c.printExecError(err)
-			return 1
+			return c.Session.Status()
 		}
 		if e, ok := a.(exit); ok {
 			return e.code
@@ -111,8 +162,8 @@ func (c *CLI) run(args []string, flagC *string, flagE *bool) int {
 		// The -c flag.
 		return c.fromArg(*flagC)
 	case len(args) > 0:
-		// Execute files.
-		return c.executeFiles(args)
+		// Execute the script with the rest of the arguments.
+		return c.executeScript(args[0], args[1:])
 	default:
 		// Interactive mode.
 		return c.runInteractiveMode()
@@ -120,27 +171,28 @@ func (c *CLI) run(args []string, flagC *string, flagE *bool) int {
 }
 
 func (c *CLI) fromArg(program string) int {
-	a, err := c.execute1([]byte(program))
+	a, err := c.execute1("command line", []byte(program))
 	if err != nil {
 		c.printExecError(err)
-		return 1
+		return c.Session.Status()
 	}
 	if e, ok := a.(exit); ok {
 		return e.code
 	}
-	return 0
+	return c.Session.Status()
 }
 
-func (c *CLI) executeFiles(args []string) int {
-	a, err := c.runFiles(args)
+func (c *CLI) executeScript(file string, args []string) int {
+	c.Session.SetArgs(args)
+	a, err := c.runFile(file)
 	if err != nil {
 		c.printExecError(err)
-		return 1
+		return c.Session.Status()
 	}
 	if e, ok := a.(exit); ok {
 		return e.code
 	}
-	return 0
+	return c.Session.Status()
 }
 
 // runInteractiveMode runs interactive mode.
@@ -153,11 +205,19 @@ func (c *CLI) runInteractiveMode() int {
 		return 1
 	}
 
+	if terminal.IsTerminal(0) {
+		if err := c.Session.Jobs().EnableJobControl(0); err != nil {
+			c.errorln("no job control:", err)
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	g := gate.NewContext(ctx, c.Config, c.In, c.Out, c.Err, histRunes)
+	g.SetIncomplete(c.incomplete)
 	for {
+		c.Session.Jobs().Report(c.Err)
 		a, err := c.interact(g)
 		if err != nil {
 			c.printExecError(err)
@@ -190,6 +250,7 @@ func (c *CLI) getHistory(filename string) ([][]rune, error) {
 			return nil, errors.Wrap(err, "connecting history file")
 		}
 		c.DB = db
+		c.Session.DB = db
 	}
 	_, err := c.DB.Exec(schema)
 	if err != nil {
@@ -204,9 +265,12 @@ func (c *CLI) getHistory(filename string) ([][]rune, error) {
 }
 
 func (c *CLI) printExecError(err error) {
-	if pe, ok := err.(*eparser.ParseError); ok {
-		c.errorln(pe.Verbose())
-	} else {
+	switch x := err.(type) {
+	case *eparser.ParseError:
+		c.errorln(x.Verbose())
+	case *sourceError:
+		c.errorln(x.Verbose())
+	default:
 		c.errorln(err)
 	}
 }
@@ -274,7 +338,7 @@ func (c *CLI) interact(g gate.Gate) (action, error) {
 		return exitSuccess, nil
 	}
 	ch := c.writeHistory(r)
-	a, err := c.execute1([]byte(string(r)))
+	a, err := c.execute1("command line", []byte(string(r)))
 	if err != nil {
 		return a, err
 	}
@@ -321,52 +385,25 @@ create table if not exists command_info (
     line text
 )`
 
-func (c *CLI) execute(b []byte) (action, error) {
-	f, err := parser.ParseSrc(b)
-	if err != nil {
-		return nil, err
+func (c *CLI) execute(name string, b []byte) (action, error) {
+	exited, err := c.Session.Eval(name, b)
+	if exited {
+		return exit{c.Session.Status()}, nil
 	}
-	e := eval.New(c.In, c.Out, c.Err, c.DB)
-	err = e.Eval(f.Lines)
-	select {
-	case code := <-e.ExitCh:
-		return exit{code}, nil
-	default:
-	}
-	return nil, err
+	return nil, c.locate(err, b)
 }
 
-func (c *CLI) executeExtra(b []byte) (action, error) {
-	cmd, err := eparser.Parse(b)
-	if err != nil {
-		return nil, err
-	}
-	e := extra.New(extra.Option{DB: c.DB})
-	err = e.Eval(cmd)
-	if err == nil {
-		return nil, nil
-	}
-	if pe, ok := err.(*eparser.ParseError); ok {
-		pe.Src = string(b)
-	}
-	return nil, err
+func (c *CLI) executeExtra(name string, b []byte) (action, error) {
+	return nil, c.Session.EvalExtra(name, b)
 }
 
-func (c *CLI) runFiles(files []string) (action, error) {
-	for _, file := range files {
-		b, err := ioutil.ReadFile(file)
-		if err != nil {
-			return nil, err
-		}
-		a, err := c.execute1(b)
-		if err != nil {
-			return nil, err
-		}
-		if a != nil {
-			return a, nil
-		}
+func (c *CLI) runFile(file string) (action, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		c.Session.SetStatus(1)
+		return nil, err
 	}
-	return nil, nil
+	return c.execute1(file, b)
 }
 
 type action interface {
@@ -3,12 +3,16 @@ package cli
 import (
 	"bytes"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/elpinal/coco3/config"
 	"github.com/elpinal/coco3/editor"
+	"github.com/elpinal/coco3/session"
 )
 
 func TestFlagC(t *testing.T) {
@@ -30,6 +34,70 @@ func TestFlagC(t *testing.T) {
 	}
 }
 
+func TestExtraPipe(t *testing.T) {
+	var out, err bytes.Buffer
+	c := CLI{
+		Out: &out,
+		Err: &err,
+	}
+	args := []string{"-extra", "-c", "exec 'echo' ['abc'] | exec 'tr' ['a', 'x']"}
+	code := c.Run(args)
+	if code != 0 {
+		t.Errorf("Run: got %v, want %v", code, 0)
+	}
+	if got, want := out.String(), "xbc\n"; got != want {
+		t.Errorf("output: got %q, want %q", got, want)
+	}
+	if e := err.String(); e != "" {
+		t.Errorf("error: %v", e)
+	}
+}
+
+func TestExtraRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coco3-extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	c := CLI{
+		Out: &out,
+		Err: &errOut,
+	}
+	src := "def d = '" + dir + "'\n" +
+		"mkdir {parents = true} '" + filepath.Join(dir, "a", "b") + "'\n" +
+		"mkdir {} '" + filepath.Join(dir, "c") + "'\n" +
+		"cd d\n"
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if code := c.Run([]string{"-extra", "-c", src}); code != 0 {
+		t.Errorf("Run: got %v, want %v: %s", code, 0, errOut.String())
+	}
+	for _, name := range []string{filepath.Join("a", "b"), "c"} {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err != nil || !fi.IsDir() {
+			t.Errorf("%s is not created: %v", name, err)
+		}
+	}
+	if got, err := os.Getwd(); err != nil || got != dir {
+		t.Errorf("working directory: got %q, want %q", got, dir)
+	}
+
+	c = CLI{
+		Out: &out,
+		Err: &errOut,
+	}
+	if code := c.Run([]string{"-extra", "-c", "mkdir {parent = true} 'x'"}); code != 1 {
+		t.Errorf("Run: got %v, want %v", code, 1)
+	}
+}
+
 func TestArgs(t *testing.T) {
 	var out, err bytes.Buffer
 	c := CLI{
@@ -90,6 +158,39 @@ func TestStartUpCommand(t *testing.T) {
 	}
 }
 
+func TestConfigAlias(t *testing.T) {
+	var out, err bytes.Buffer
+	c := CLI{
+		Out: &out,
+		Err: &err,
+		Config: &config.Config{
+			Alias: [][2]string{{"greet", "echo 'hello,  world'"}},
+		},
+	}
+	code := c.Run([]string{"-c", "greet again; alias"})
+	if code != 0 {
+		t.Errorf("Run: got %v, want %v", code, 0)
+	}
+	if got, want := out.String(), "hello,  world again\ngreet\techo 'hello,  world'\t(config)\n"; got != want {
+		t.Errorf("output: got %q, want %q", got, want)
+	}
+	if e := err.String(); e != "" {
+		t.Errorf("error: %v", e)
+	}
+}
+
+func TestSharedSession(t *testing.T) {
+	var out bytes.Buffer
+	s := session.New(nil, &out, ioutil.Discard, nil)
+	for _, program := range []string{"x=1; func f { echo $x $1 }", "f a; false", "status"} {
+		c := CLI{Session: s}
+		c.Run([]string{"-c", program})
+	}
+	if got, want := out.String(), "1 a\n1\n"; got != want {
+		t.Errorf("output: got %q, want %q", got, want)
+	}
+}
+
 func TestExitInStartUp(t *testing.T) {
 	var out, err bytes.Buffer
 	c := CLI{
@@ -112,6 +213,24 @@ func TestExitInStartUp(t *testing.T) {
 	}
 }
 
+func TestScript(t *testing.T) {
+	var out, err bytes.Buffer
+	c := CLI{
+		Out: &out,
+		Err: &err,
+	}
+	code := c.Run([]string{"testdata/script.coco", "a", "b c"})
+	if code != 0 {
+		t.Errorf("Run: got %v, want %v", code, 0)
+	}
+	if got, want := out.String(), "2 a b c\narg a\narg b c\nfrom lib\na\nhello, world\n"; got != want {
+		t.Errorf("output: got %q, want %q", got, want)
+	}
+	if e := err.String(); e != "" {
+		t.Errorf("error: %v", e)
+	}
+}
+
 func TestExitInFiles(t *testing.T) {
 	var out, err bytes.Buffer
 	c := CLI{
@@ -277,3 +396,126 @@ func TestStartup(t *testing.T) {
 		t.Errorf("Run = %d, want %d", n, want)
 	}
 }
+
+func TestFlagConfig(t *testing.T) {
+	var out, err bytes.Buffer
+	c := CLI{
+		Out: &out,
+		Err: &err,
+	}
+	args := []string{"-config", "testdata/config.toml", "-c", "greet world"}
+	code := c.Run(args)
+	if code != 0 {
+		t.Errorf("Run: got %v, want %v", code, 0)
+	}
+	if got, want := out.String(), "configured\nhello world\n"; got != want {
+		t.Errorf("output: got %q, want %q", got, want)
+	}
+	if e := err.String(); e != "" {
+		t.Errorf("error: %v", e)
+	}
+}
+
+func TestFlagConfigNotFound(t *testing.T) {
+	var out, err bytes.Buffer
+	c := CLI{
+		Out: &out,
+		Err: &err,
+	}
+	args := []string{"-config", "testdata/nonexistent.toml", "-c", "echo aaa"}
+	code := c.Run(args)
+	if code != 2 {
+		t.Errorf("Run: got %v, want %v", code, 2)
+	}
+	if got := out.String(); got != "" {
+		t.Errorf("output: got %q, want %q", got, "")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		args []string
+		code int
+		out  string
+	}{
+		{[]string{"-c", "false"}, 1, ""},
+		{[]string{"-c", "false; echo aaa"}, 0, "aaa\n"},
+		{[]string{"-c", "sh -c 'exit 3'; status"}, 0, "3\n"},
+		{[]string{"-c", "sh -c 'exit 3'; exit"}, 3, ""},
+		{[]string{"testdata/status.coco"}, 1, "aaa\n"},
+		{[]string{"testdata/control.coco"}, 0, "skip a\nfound b\nskip c\n"},
+	}
+	for _, test := range tests {
+		var out, err bytes.Buffer
+		c := CLI{
+			Out: &out,
+			Err: &err,
+		}
+		code := c.Run(test.args)
+		if code != test.code {
+			t.Errorf("Run(%q): got %v, want %v", test.args, code, test.code)
+		}
+		if got := out.String(); got != test.out {
+			t.Errorf("Run(%q): output: got %q, want %q", test.args, got, test.out)
+		}
+		if e := err.String(); e != "" {
+			t.Errorf("Run(%q): error: %v", test.args, e)
+		}
+	}
+}
+
+func TestErrorReport(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			[]string{"-c", "echo a\necho )"},
+			"command line:2:6: expected expression, found ')'\n\n2: echo )\n        ^ error occurs\n",
+		},
+		{
+			[]string{"-c", "echo a; nosuch b"},
+			"command line:1:9: exec: \"nosuch\": executable file not found in $PATH\n\n1: echo a; nosuch b\n           ^ error occurs\n",
+		},
+		{
+			[]string{"-c", "cd /nonexistent || echo a"},
+			"command line:1:1: cd: chdir /nonexistent: no such file or directory\n\n1: cd /nonexistent || echo a\n   ^ error occurs\n",
+		},
+		{
+			[]string{"-c", "func f {\n\tcat < /nonexistent\n}\nf"},
+			"command line:2:6: f: open /nonexistent: no such file or directory\n\n2: \tcat < /nonexistent\n   \t    ^ error occurs\n",
+		},
+		{
+			[]string{"testdata/error.coco"},
+			"testdata/error.coco:2:5: open testdata/nonexistent: no such file or directory\n\n2: cat < testdata/nonexistent\n       ^ error occurs\n",
+		},
+		{
+			[]string{"testdata/eof.coco"},
+			"testdata/eof.coco:2:8: expected ')', found 'EOF'\n\n2: echo (b\n          ^ error occurs\n",
+		},
+		{
+			[]string{"-extra", "-c", "def x = 'a'; nosuch x"},
+			"command line:1:14: no such typed command: \"nosuch\"\n\n1: def x = 'a'; nosuch x\n                ^ error occurs\n",
+		},
+		{
+			[]string{"-extra", "testdata/error.x"},
+			"testdata/error.x:4:1: stat testdata/nonexistent: no such file or directory\n\n4: mv 'testdata/nonexistent' y\n   ^ error occurs\n",
+		},
+	}
+	for _, test := range tests {
+		var out, err bytes.Buffer
+		c := CLI{
+			Out: &out,
+			Err: &err,
+		}
+		c.Run(test.args)
+		if got := stripColor(err.String()); got != test.want {
+			t.Errorf("Run(%q): error: got %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+// stripColor removes the escape sequences for colors from s.
+func stripColor(s string) string {
+	return regexp.MustCompile("\033\\[[0-9;]*m").ReplaceAllString(s, "")
+}
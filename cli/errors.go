@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/elpinal/color"
+
+	"github.com/elpinal/coco3/eval"
+	"github.com/elpinal/coco3/scanner"
+	"github.com/elpinal/coco3/token"
+)
+
+// A sourceError is an error with the position and the line of the source
+// where it occurs.
+type sourceError struct {
+	pos  token.Position
+	line string
+	msg  string
+	err  error // original error
+}
+
+func (e *sourceError) Error() string {
+	return e.pos.String() + ": " + e.msg
+}
+
+func (e *sourceError) Cause() error {
+	return e.err
+}
+
+// Verbose returns the error with the source line and a caret pointing at the
+// column.
+func (e *sourceError) Verbose() string {
+	var buf bytes.Buffer
+	buf.WriteString(e.Error())
+	buf.WriteString("\n\n")
+
+	l := fmt.Sprintf("%d: ", e.pos.Line)
+	buf.WriteString(color.Wrap(l, color.Cyan))
+	buf.WriteString(e.line)
+	buf.WriteByte('\n')
+
+	buf.WriteString(strings.Repeat(" ", len(l)))
+	buf.WriteString(caretIndent(e.line, e.pos.Column))
+	buf.WriteString("\033[1m^ error occurs\033[0m")
+	return buf.String()
+}
+
+// caretIndent returns the blank which has the same width as line before
+// column. Tabs are kept so that the caret lines up with the source.
+func caretIndent(line string, column int) string {
+	n := column - 1
+	if n > len(line) {
+		n = len(line)
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, line[:n])
+}
+
+// sourceLine returns the line of src containing offset.
+func sourceLine(src []byte, offset int) string {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	start := bytes.LastIndexByte(src[:offset], '\n') + 1
+	end := bytes.IndexByte(src[offset:], '\n')
+	if end < 0 {
+		return string(src[start:])
+	}
+	return string(src[start : offset+end])
+}
+
+// atLastLine moves pos from the end of src, which ends with a newline, to the
+// end of the last non-empty line, so that an error at EOF points at the line
+// left incomplete.
+func atLastLine(src []byte, pos token.Position) token.Position {
+	if pos.Offset != len(src) || !bytes.HasSuffix(src, []byte("\n")) {
+		return pos
+	}
+	src = bytes.TrimRight(src, " \t\r\n")
+	if len(src) == 0 {
+		return pos
+	}
+	start := bytes.LastIndexByte(src, '\n') + 1
+	pos.Offset = len(src)
+	pos.Line = bytes.Count(src, []byte("\n")) + 1
+	pos.Column = len(src) - start + 1
+	return pos
+}
+
+// locate returns err as a sourceError if its position is known. Parse errors
+// are located in src.
+func (c *CLI) locate(err error, src []byte) error {
+	if list, ok := err.(scanner.ErrorList); ok {
+		e := list[0]
+		msg := e.Msg
+		if len(list) > 1 {
+			msg += fmt.Sprintf(" (and %d more errors)", len(list)-1)
+		}
+		pos := atLastLine(src, e.Pos)
+		return &sourceError{pos: pos, line: sourceLine(src, pos.Offset), msg: msg, err: err}
+	}
+	f := c.Session.FileSet().File(eval.ErrorPos(err))
+	if f == nil {
+		return err
+	}
+	pos := f.Position(eval.ErrorPos(err))
+	src, ok := c.Session.Source(f)
+	if !ok {
+		// A file evaluated by source.
+		src, _ = ioutil.ReadFile(f.Name())
+	}
+	pos = atLastLine(src, pos)
+	return &sourceError{pos: pos, line: sourceLine(src, pos.Offset), msg: err.Error(), err: err}
+}
@@ -0,0 +1,4 @@
+def x = 'a'
+def y = x
+
+mv 'testdata/nonexistent' y
@@ -6,25 +6,34 @@ import (
 	"text/template"
 )
 
-const defaultPrompt = "_ "
+const (
+	defaultPrompt             = "_ "
+	defaultContinuationPrompt = "> "
+)
 
 var defaultHistFile = filepath.Join(os.Getenv("HOME"), ".coco3_history")
 
 type Config struct {
-	Prompt         string
-	PromptTmpl     *template.Template
-	StartUpCommand []byte
-	Alias          [][2]string
-	HistFile       string
-	Env            map[string]string
-	Paths          []string
-	Extra          bool
+	Prompt     string
+	PromptTmpl *template.Template
+	// ContinuationPrompt is shown at the beginning of the following lines
+	// of an incomplete input.
+	ContinuationPrompt string
+	StartUpCommand     []byte
+	Alias              [][2]string
+	HistFile           string
+	Env                map[string]string
+	Paths              []string
+	Extra              bool
 }
 
 func (c *Config) Init() {
 	if c.Prompt == "" {
 		c.Prompt = defaultPrompt
 	}
+	if c.ContinuationPrompt == "" {
+		c.ContinuationPrompt = defaultContinuationPrompt
+	}
 	if c.HistFile == "" {
 		c.HistFile = defaultHistFile
 	}
@@ -0,0 +1,395 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// DefaultFile returns the path of the configuration file read at startup
+// when no file is specified explicitly.
+func DefaultFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "coco3", "config.toml")
+}
+
+// An Error describes a problem in a configuration file.
+type Error struct {
+	Filename string
+	Line     int // starting at 1
+	Column   int // starting at 1; 0 if unknown
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	pos := fmt.Sprint(e.Line)
+	if e.Column > 0 {
+		pos += fmt.Sprintf(":%d", e.Column)
+	}
+	if e.Filename == "" {
+		return fmt.Sprintf("%s: %s", pos, e.Msg)
+	}
+	return fmt.Sprintf("%s:%s: %s", e.Filename, pos, e.Msg)
+}
+
+// Load reads the configuration file named filename and overwrites the
+// fields of c with the values found in it.
+func (c *Config) Load(filename string) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return c.Parse(filename, b)
+}
+
+// Parse parses src as a configuration file and overwrites the fields of c
+// with the values found in it. The syntax is a subset of TOML:
+//
+//	prompt = "$ "
+//	prompt_template = "{{.WD}} $ "
+//	continuation_prompt = "> "
+//	startup = "echo hello"
+//	histfile = "/path/to/history"
+//	paths = ["/usr/local/go/bin", "/opt/bin"]
+//	extra = false
+//
+//	[alias]
+//	ll = "ls -l"
+//
+//	[env]
+//	EDITOR = "vim"
+//
+// filename is only used for error messages.
+func (c *Config) Parse(filename string, src []byte) error {
+	p := &confParser{filename: filename, src: src, line: 1}
+	return p.parse(c)
+}
+
+type confParser struct {
+	filename string
+	src      []byte
+	off      int
+	line     int
+	lineOff  int // offset of the current line
+
+	table string
+}
+
+func (p *confParser) errorf(format string, a ...interface{}) error {
+	return p.errorAt(p.line, format, a...)
+}
+
+func (p *confParser) errorAt(line int, format string, a ...interface{}) error {
+	return &Error{Filename: p.filename, Line: line, Msg: fmt.Sprintf(format, a...)}
+}
+
+// column returns the column of the current position, starting at 1.
+func (p *confParser) column() int {
+	return p.off - p.lineOff + 1
+}
+
+func (p *confParser) peek() rune {
+	if p.off >= len(p.src) {
+		return -1
+	}
+	r, _ := utf8.DecodeRune(p.src[p.off:])
+	return r
+}
+
+func (p *confParser) next() rune {
+	if p.off >= len(p.src) {
+		return -1
+	}
+	r, w := utf8.DecodeRune(p.src[p.off:])
+	p.off += w
+	if r == '\n' {
+		p.line++
+		p.lineOff = p.off
+	}
+	return r
+}
+
+func (p *confParser) skipSpace() {
+	for r := p.peek(); r == ' ' || r == '\t' || r == '\r'; r = p.peek() {
+		p.next()
+	}
+}
+
+// skipBlank skips whitespaces, newlines and comments.
+func (p *confParser) skipBlank() {
+	for {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n':
+			p.next()
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *confParser) skipComment() {
+	for r := p.peek(); r != '\n' && r != -1; r = p.peek() {
+		p.next()
+	}
+}
+
+// endLine consumes the rest of the current line, which must contain only
+// whitespaces or a comment.
+func (p *confParser) endLine() error {
+	p.skipSpace()
+	switch p.peek() {
+	case '#':
+		p.skipComment()
+	case '\n', -1:
+	default:
+		return p.errorf("unexpected %q after value", p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *confParser) parse(c *Config) error {
+	for {
+		p.skipBlank()
+		switch p.peek() {
+		case -1:
+			return nil
+		case '[':
+			if err := p.parseTable(); err != nil {
+				return err
+			}
+		default:
+			if err := p.parseKeyValue(c); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *confParser) parseTable() error {
+	p.next() // '['
+	p.skipSpace()
+	name, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.next() != ']' {
+		return p.errorf("expected ']' after table name")
+	}
+	switch name {
+	case "alias", "env":
+	default:
+		return p.errorf("unknown table: %q", name)
+	}
+	p.table = name
+	return p.endLine()
+}
+
+func (p *confParser) parseKeyValue(c *Config) error {
+	line := p.line
+	key, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.next() != '=' {
+		return p.errorf("expected '=' after key %q", key)
+	}
+	p.skipSpace()
+	v, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	if err := p.set(c, line, key, v); err != nil {
+		return err
+	}
+	return p.endLine()
+}
+
+func isBareKey(r rune) bool {
+	return 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '_' || r == '-'
+}
+
+func (p *confParser) parseKey() (string, error) {
+	if p.peek() == '"' {
+		return p.parseString()
+	}
+	start := p.off
+	for isBareKey(p.peek()) {
+		p.next()
+	}
+	if start == p.off {
+		return "", p.errorf("expected key, found %q", p.peek())
+	}
+	return string(p.src[start:p.off]), nil
+}
+
+func (p *confParser) parseValue() (interface{}, error) {
+	switch r := p.peek(); {
+	case r == '"':
+		return p.parseString()
+	case r == '\'':
+		return p.parseLiteralString()
+	case r == '[':
+		return p.parseArray()
+	case isBareKey(r):
+		start := p.off
+		for isBareKey(p.peek()) {
+			p.next()
+		}
+		switch s := string(p.src[start:p.off]); s {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		default:
+			return nil, p.errorf("invalid value: %q", s)
+		}
+	default:
+		return nil, p.errorf("expected value, found %q", r)
+	}
+}
+
+func (p *confParser) parseString() (string, error) {
+	line, col := p.line, p.column()
+	p.next() // '"'
+	var b strings.Builder
+	for {
+		switch r := p.next(); r {
+		case -1, '\n':
+			// Report where the string starts; the newline has already
+			// moved p.line on.
+			return "", &Error{Filename: p.filename, Line: line, Column: col, Msg: "string not terminated"}
+		case '"':
+			return b.String(), nil
+		case '\\':
+			switch e := p.next(); e {
+			case '"', '\\':
+				b.WriteRune(e)
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				return "", p.errorf("unknown escape sequence: \\%c", e)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+func (p *confParser) parseLiteralString() (string, error) {
+	line, col := p.line, p.column()
+	p.next() // '\''
+	start := p.off
+	for {
+		switch p.next() {
+		case -1, '\n':
+			// Report where the string starts; the newline has already
+			// moved p.line on.
+			return "", &Error{Filename: p.filename, Line: line, Column: col, Msg: "string not terminated"}
+		case '\'':
+			return string(p.src[start : p.off-1]), nil
+		}
+	}
+}
+
+func (p *confParser) parseArray() ([]string, error) {
+	p.next() // '['
+	var list []string
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.next()
+			return list, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, p.errorf("array elements must be strings")
+		}
+		list = append(list, s)
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *confParser) set(c *Config, line int, key string, v interface{}) error {
+	switch p.table {
+	case "alias":
+		s, ok := v.(string)
+		if !ok {
+			return p.errorAt(line, "alias %q: expected string", key)
+		}
+		c.Alias = append(c.Alias, [2]string{key, s})
+		return nil
+	case "env":
+		s, ok := v.(string)
+		if !ok {
+			return p.errorAt(line, "env %q: expected string", key)
+		}
+		if c.Env == nil {
+			c.Env = make(map[string]string)
+		}
+		c.Env[key] = s
+		return nil
+	}
+	switch key {
+	case "prompt", "prompt_template", "continuation_prompt", "startup", "histfile":
+		s, ok := v.(string)
+		if !ok {
+			return p.errorAt(line, "%s: expected string", key)
+		}
+		switch key {
+		case "prompt":
+			c.Prompt = s
+		case "prompt_template":
+			t, err := template.New("prompt").Parse(s)
+			if err != nil {
+				return p.errorAt(line, "%s: %v", key, err)
+			}
+			c.PromptTmpl = t
+		case "continuation_prompt":
+			c.ContinuationPrompt = s
+		case "startup":
+			c.StartUpCommand = []byte(s)
+		case "histfile":
+			c.HistFile = s
+		}
+	case "paths":
+		list, ok := v.([]string)
+		if !ok {
+			return p.errorAt(line, "%s: expected array of strings", key)
+		}
+		c.Paths = list
+	case "extra":
+		b, ok := v.(bool)
+		if !ok {
+			return p.errorAt(line, "%s: expected boolean", key)
+		}
+		c.Extra = b
+	default:
+		return p.errorAt(line, "unknown key: %q", key)
+	}
+	return nil
+}
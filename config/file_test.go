@@ -0,0 +1,112 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	src := `# comment
+prompt = "$ "
+continuation_prompt = ".. "
+startup = 'echo hello'
+histfile = "/tmp/history" # trailing comment
+paths = [
+	"/a/bin",
+	"/b/bin",
+]
+extra = true
+
+[alias]
+ll = "ls -l"
+"g" = "git"
+
+[env]
+EDITOR = "vim"
+`
+	var c Config
+	if err := c.Parse("config.toml", []byte(src)); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := Config{
+		Prompt:             "$ ",
+		ContinuationPrompt: ".. ",
+		StartUpCommand:     []byte("echo hello"),
+		HistFile:           "/tmp/history",
+		Paths:              []string{"/a/bin", "/b/bin"},
+		Extra:              true,
+		Alias:              [][2]string{{"ll", "ls -l"}, {"g", "git"}},
+		Env:                map[string]string{"EDITOR": "vim"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Parse: got %+v, want %+v", c, want)
+	}
+}
+
+func TestParsePromptTemplate(t *testing.T) {
+	var c Config
+	if err := c.Parse("", []byte(`prompt_template = "{{.WD}} "`)); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if c.PromptTmpl == nil {
+		t.Error("PromptTmpl should be set")
+	}
+}
+
+func TestParseFail(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+	}{
+		{"prompt = \"a\"\nunknown = \"b\"", 2},
+		{"\n\nprompt = 1", 3},
+		{"extra = \"yes\"", 1},
+		{"paths = \"/bin\"", 1},
+		{"[unknown]", 1},
+		{"prompt = \"a", 1},
+		{"prompt = \"a\nextra = true", 1},
+		{"[alias]\nll = 'ls\n", 2},
+		{"prompt \"a\"", 1},
+		{"prompt = \"a\" b", 1},
+		{"[env]\nX = true", 2},
+		{"prompt_template = \"{{\"", 1},
+	}
+	for _, test := range tests {
+		var c Config
+		err := c.Parse("config.toml", []byte(test.src))
+		if err == nil {
+			t.Errorf("Parse(%q): unexpectedly succeeded", test.src)
+			continue
+		}
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Parse(%q): got %T, want *Error", test.src, err)
+			continue
+		}
+		if e.Line != test.line {
+			t.Errorf("Parse(%q): line: got %d, want %d (%v)", test.src, e.Line, test.line, e)
+		}
+	}
+}
+
+func TestParseUnterminated(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"prompt = \"a\nextra = true", "config.toml:1:10: string not terminated"},
+		{"[alias]\nll = 'ls\n", "config.toml:2:6: string not terminated"},
+		{"prompt = \"a", "config.toml:1:10: string not terminated"},
+	}
+	for _, test := range tests {
+		var c Config
+		err := c.Parse("config.toml", []byte(test.src))
+		if err == nil {
+			t.Errorf("Parse(%q): unexpectedly succeeded", test.src)
+			continue
+		}
+		if got := err.Error(); got != test.want {
+			t.Errorf("Parse(%q): got %q, want %q", test.src, got, test.want)
+		}
+	}
+}
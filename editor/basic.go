@@ -123,6 +123,20 @@ func (e *basic) lastIndex(ch rune, last int) int {
 	return -1
 }
 
+// lineStart returns the position of the beginning of the line at pos.
+func (e *basic) lineStart(pos int) int {
+	return e.lastIndex('\n', pos) + 1
+}
+
+// lineEnd returns the position of the end of the line at pos, which is the
+// newline character or the end of the buffer.
+func (e *basic) lineEnd(pos int) int {
+	if i := e.index('\n', pos); i >= 0 {
+		return i
+	}
+	return len(e.buf)
+}
+
 // indexFunc(f, start, true) == indexFunc(func(r) bool { return !f(r) }, start, false)
 func (e *basic) indexFunc(f func(rune) bool, start int, truth bool) int {
 	start = constrain(start, 0, len(e.buf))
@@ -14,6 +14,7 @@ type Editor interface {
 	Read() ([]rune, bool, error)
 	Clear()
 	SetHistory([][]rune)
+	SetIncomplete(func([]rune) bool)
 }
 
 func New(s screen.Screen, conf *config.Config, in io.Reader, out, err io.Writer) Editor {
@@ -51,6 +52,9 @@ type balancer struct {
 	*editor
 	s    screen.Screen
 	conf *config.Config
+
+	// incomplete reports whether the input needs more lines; or nil.
+	incomplete func([]rune) bool
 }
 
 func (b *balancer) Read() ([]rune, bool, error) {
@@ -65,6 +69,13 @@ func (b *balancer) Read() ([]rune, bool, error) {
 		if end == exit {
 			return nil, true, nil
 		}
+		if end == execute && b.continues(m) {
+			// Start a new line instead of executing the input.
+			b.buf = append(b.buf, '\n')
+			b.pos = len(b.buf)
+			b.s.Refresh(b.conf, false, m.Runes(), m.Position(), m.Highlight())
+			continue
+		}
 		if end == execute {
 			b.s.SetLastLine("")
 			b.s.Refresh(b.conf, false, m.Runes(), m.Position(), m.Highlight())
@@ -82,6 +93,13 @@ func (b *balancer) Read() ([]rune, bool, error) {
 	}
 }
 
+// continues reports whether the input of m is incomplete and continues on
+// the next line.
+func (b *balancer) continues(m moder) bool {
+	i, ok := m.(*insert)
+	return ok && !i.replaceMode && b.incomplete != nil && b.incomplete(b.buf)
+}
+
 func (b *balancer) Clear() {
 	b.buf = b.buf[:0]
 	b.pos = 0
@@ -97,6 +115,13 @@ func (b *balancer) SetHistory(history [][]rune) {
 	b.age = len(history)
 }
 
+// SetIncomplete sets the function which reports whether the input is
+// incomplete. Then the key to execute the incomplete input starts a new line
+// instead.
+func (b *balancer) SetIncomplete(f func([]rune) bool) {
+	b.incomplete = f
+}
+
 const (
 	mchar = iota
 	mline
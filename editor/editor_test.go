@@ -30,6 +30,21 @@ func TestEditor(t *testing.T) {
 	e.Clear()
 }
 
+func TestIncomplete(t *testing.T) {
+	inBuf := strings.NewReader("echo (a" + string([]rune{CharCtrlM, 'b', ')', CharCtrlM}))
+	e := New(&screen.TestScreen{}, &config.Config{}, inBuf, ioutil.Discard, ioutil.Discard)
+	e.SetIncomplete(func(r []rune) bool {
+		return strings.Count(string(r), "(") > strings.Count(string(r), ")")
+	})
+	s, _, err := e.Read()
+	if err != nil {
+		t.Error(err)
+	}
+	if want := "echo (a\nb)"; string(s) != want {
+		t.Errorf("got %q, want %q", string(s), want)
+	}
+}
+
 func TestNormal(t *testing.T) {
 	inBuf := strings.NewReader("aaa" + string([]rune{
 		CharEscape,
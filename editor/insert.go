@@ -240,7 +240,7 @@ func (e *insert) deleteWord() {
 
 func (e *insert) deleteToBeginning() {
 	if !e.replaceMode {
-		e.delete(0, e.pos)
+		e.delete(e.lineStart(e.pos), e.pos)
 		return
 	}
 	if e.pos == 0 {
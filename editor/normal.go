@@ -151,18 +151,18 @@ var normalCommands = map[rune]normalCommand{
 }
 
 func (e *nvCommon) endline() (_ modeChanger) {
-	e.move(len(e.buf))
+	e.move(e.lineEnd(e.pos))
 	return
 }
 
 func (e *nvCommon) beginline() (_ modeChanger) {
-	e.move(0)
+	e.move(e.lineStart(e.pos))
 	return
 }
 
 func (e *nvCommon) beginlineNonBlank() (_ modeChanger) {
-	i := e.indexFunc(isWhitespace, 0, false)
-	if i < 0 {
+	i := e.indexFunc(isWhitespace, e.lineStart(e.pos), false)
+	if i < 0 || i > e.lineEnd(e.pos) {
 		return e.endline()
 	}
 	e.move(i)
@@ -1,27 +1,127 @@
 package eval
 
-import "strings"
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/pkg/errors"
+
+	"github.com/elpinal/coco3/ast"
+	"github.com/elpinal/coco3/parser"
+	"github.com/elpinal/coco3/token"
+)
+
+// Aliases is a store of aliases. It is safe for concurrent use.
+type Aliases struct {
+	mu sync.Mutex
+	m  map[string]*alias
+}
+
+// An alias is a command with arguments and redirections which replaces the
+// first word of a command.
 type alias struct {
-	cmd  string
-	args []string
+	name   string
+	body   string // source of stmt
+	origin string // where the alias was defined, e.g. "config"
+	stmt   *ast.ExecStmt
+}
+
+func NewAliases() *Aliases {
+	return &Aliases{m: make(map[string]*alias)}
+}
+
+// Define defines an alias named name. The body is parsed as a command, which
+// may have quoted arguments, lists and redirections. Origin describes where
+// the alias is defined; it is shown by the alias built-in command.
+func (a *Aliases) Define(name, body, origin string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("invalid alias name: %q", name)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), origin, []byte(body))
+	if err != nil {
+		return errors.Wrapf(err, "alias %s", name)
+	}
+	var stmt *ast.ExecStmt
+	if len(f.Lines) == 1 {
+		stmt, _ = f.Lines[0].(*ast.ExecStmt)
+	}
+	if stmt == nil || len(stmt.Args) == 0 {
+		return fmt.Errorf("alias %s: body must be a single command: %q", name, body)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.m[name] = &alias{name: name, body: body, origin: origin, stmt: stmt}
+	return nil
 }
 
-var aliases = make(map[string]alias)
+func (a *Aliases) get(name string) (*alias, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	x, ok := a.m[name]
+	return x, ok
+}
+
+// remove removes the alias named name, and reports whether it was defined.
+func (a *Aliases) remove(name string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.m[name]
+	delete(a.m, name)
+	return ok
+}
+
+// list returns the aliases in sorted order of name.
+func (a *Aliases) list() []*alias {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l := make([]*alias, 0, len(a.m))
+	for _, x := range a.m {
+		l = append(l, x)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].name < l[j].name })
+	return l
+}
 
-func DefAlias(name, arg string) {
-	// TODO: Support more complex syntax.
-	a := strings.Split(arg, " ")
-	cmd := a[0]
-	args := a[1:]
-	for i := range args {
-		if args[i] == "''" {
-			args[i] = ""
+// expandAlias replaces the first word of args with its alias, if any. The
+// redirections of the alias precede redirs. Expansion repeats on the result,
+// but an alias is not expanded within itself, so `ls` can be an alias of
+// `ls -F`.
+func (e *Evaluator) expandAlias(args []string, redirs []redirect) ([]string, []redirect, error) {
+	seen := make(map[string]bool)
+	for len(args) > 0 && !seen[args[0]] {
+		a, ok := e.aliases.get(args[0])
+		if !ok {
+			break
 		}
+		seen[a.name] = true
+		aargs, aredirs, err := e.evalCommand(a.stmt)
+		if err != nil {
+			return nil, nil, &aliasError{name: a.name, err: err}
+		}
+		for i := range aredirs {
+			aredirs[i].pos = token.NoPos
+		}
+		args = append(aargs, args[1:]...)
+		redirs = append(aredirs, redirs...)
 	}
-	if x, ok := aliases[cmd]; ok {
-		cmd = x.cmd
-		args = append(x.args, args...)
+	return args, redirs, nil
+}
+
+// runAlias returns a function which runs the alias named name like a builtin
+// command.
+func (e *Evaluator) runAlias(name string) func(context.Context, info) error {
+	return func(ctx context.Context, ci info) error {
+		child := e.fork(ci)
+		args, redirs, err := child.expandAlias(append([]string{name}, ci.args...), nil)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return errors.New("no command to execute")
+		}
+		return child.execCmd(ctx, args[0], args[1:], redirs...)
 	}
-	aliases[name] = alias{cmd, args}
 }
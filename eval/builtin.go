@@ -15,15 +15,25 @@ import (
 	"time"
 
 	"github.com/elpinal/coco3/editor"
+	"github.com/elpinal/coco3/token"
 	"github.com/jmoiron/sqlx"
 )
 
 type info struct {
 	stream
-	env    []string
-	exitCh chan int
-	args   []string
-	db     *sqlx.DB
+	env     []string // nil means the environment of the process
+	status  int      // exit status of the last command
+	jobs    *JobTable
+	vars    *Vars
+	aliases *Aliases
+	args    []string
+	db      *sqlx.DB
+
+	// command resolves a command name like the evaluator does.
+	command func(ctx context.Context, name string, arg ...string) Cmd
+
+	// evalFile evaluates a file in the session of the evaluator.
+	evalFile func(ci info, filename string, args []string) error
 }
 
 type stream struct {
@@ -45,6 +55,16 @@ func init() {
 		"exec":    execCmd,
 		"history": history,
 		"help":    help,
+		"status":  status,
+		"jobs":    jobs,
+		"fg":      fg,
+		"bg":      bg,
+		"wait":    waitJobs,
+		"export":  export,
+		"unset":   unset,
+		"alias":   defAlias,
+		"unalias": unalias,
+		"source":  source,
 	}
 }
 
@@ -103,8 +123,7 @@ func exit(_ context.Context, ci info) error {
 	var code int
 	switch len(ci.args) {
 	case 0:
-		//FIXME: exit with no args should use the exit code of the last command executed.
-		code = 0
+		code = ci.status
 	case 1:
 		i, err := strconv.Atoi(ci.args[0])
 		if err != nil {
@@ -114,7 +133,195 @@ func exit(_ context.Context, ci info) error {
 	default:
 		return errors.New("too many arguments")
 	}
-	ci.exitCh <- code
+	return &exitRequest{code: code}
+}
+
+func status(_ context.Context, ci info) error {
+	if len(ci.args) > 0 {
+		return errors.New("too many arguments")
+	}
+	_, err := fmt.Fprintln(ci.out, ci.status)
+	return err
+}
+
+func jobs(_ context.Context, ci info) error {
+	if len(ci.args) > 0 {
+		return errors.New("too many arguments")
+	}
+	for _, j := range ci.jobs.list() {
+		if _, err := fmt.Fprintln(ci.out, j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitJob waits for j and removes it from the job table.
+func waitJob(ctx context.Context, ci info, j *job) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-j.done:
+	}
+	ci.jobs.remove(j)
+	return j.err
+}
+
+func fg(_ context.Context, ci info) error {
+	var spec string
+	switch len(ci.args) {
+	case 0:
+	case 1:
+		spec = ci.args[0]
+	default:
+		return errors.New("too many arguments")
+	}
+	j, err := ci.jobs.lookup(spec)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(ci.out, j.line)
+	return ci.jobs.continueForeground(j, ci.err)
+}
+
+func bg(_ context.Context, ci info) error {
+	var spec string
+	switch len(ci.args) {
+	case 0:
+	case 1:
+		spec = ci.args[0]
+	default:
+		return errors.New("too many arguments")
+	}
+	j, err := ci.jobs.lookup(spec)
+	if err != nil {
+		return err
+	}
+	if j.finished() {
+		return fmt.Errorf("job %d has finished", j.id)
+	}
+	if !j.isStopped() {
+		return fmt.Errorf("job %d already in background", j.id)
+	}
+	if err := j.resume(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(ci.out, "[%d] %s &\n", j.id, j.line)
+	return err
+}
+
+// waitJobs waits for the specified jobs, or all jobs if no job is specified.
+// The exit status is that of the last job.
+func waitJobs(ctx context.Context, ci info) error {
+	var list []*job
+	for _, spec := range ci.args {
+		j, err := ci.jobs.lookup(spec)
+		if err != nil {
+			return err
+		}
+		list = append(list, j)
+	}
+	if len(ci.args) == 0 {
+		list = ci.jobs.list()
+	}
+	var err error
+	for _, j := range list {
+		err = waitJob(ctx, ci, j)
+		if err == ctx.Err() && err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// export exports shell variables to the environment. An argument of the
+// form name=value also sets the variable.
+func export(_ context.Context, ci info) error {
+	if len(ci.args) == 0 {
+		for _, name := range ci.vars.exportedNames() {
+			v, _ := ci.vars.Get(name)
+			if _, err := fmt.Fprintf(ci.out, "%s=%s\n", name, strings.Join(v, " ")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, arg := range ci.args {
+		name := arg
+		i := strings.IndexByte(arg, '=')
+		if i >= 0 {
+			name = arg[:i]
+		}
+		if !token.IsVarName(name) {
+			return fmt.Errorf("invalid variable name: %q", name)
+		}
+		if i >= 0 {
+			if err := ci.vars.Set(name, []string{arg[i+1:]}); err != nil {
+				return err
+			}
+		}
+		if err := ci.vars.Export(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defAlias defines an alias, or shows aliases with where they are defined.
+func defAlias(_ context.Context, ci info) error {
+	switch len(ci.args) {
+	case 0:
+		for _, a := range ci.aliases.list() {
+			if _, err := fmt.Fprintf(ci.out, "%s\t%s\t(%s)\n", a.name, a.body, a.origin); err != nil {
+				return err
+			}
+		}
+		return nil
+	case 1:
+		a, ok := ci.aliases.get(ci.args[0])
+		if !ok {
+			return fmt.Errorf("no such alias: %s", ci.args[0])
+		}
+		_, err := fmt.Fprintf(ci.out, "%s\t%s\t(%s)\n", a.name, a.body, a.origin)
+		return err
+	case 2:
+		return ci.aliases.Define(ci.args[0], ci.args[1], "alias command")
+	default:
+		return errors.New("too many arguments; quote the body of the alias")
+	}
+}
+
+func unalias(_ context.Context, ci info) error {
+	if len(ci.args) == 0 {
+		return errors.New("1 or more arguments required")
+	}
+	for _, name := range ci.args {
+		if !ci.aliases.remove(name) {
+			return fmt.Errorf("no such alias: %s", name)
+		}
+	}
+	return nil
+}
+
+// source evaluates a file in the current session. The rest of the arguments,
+// if any, become the positional parameters while evaluating it.
+func source(_ context.Context, ci info) error {
+	if len(ci.args) == 0 {
+		return errors.New("1 or more arguments required")
+	}
+	var args []string
+	if len(ci.args) > 1 {
+		args = ci.args[1:]
+	}
+	return ci.evalFile(ci, ci.args[0], args)
+}
+
+func unset(_ context.Context, ci info) error {
+	for _, name := range ci.args {
+		if err := ci.vars.Unset(name); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -161,14 +368,12 @@ func let(ctx context.Context, ci info) error {
 	for i := 0; i < n; i += 2 {
 		newEnv = append(newEnv, ci.args[i]+"="+ci.args[i+1])
 	}
-	name := ci.args[n+1]
-	// Builtin command is not supported.
-	// For instance, `let ... in cd` does actually execute /usr/bin/cd.
-	cmd := exec.CommandContext(ctx, name, ci.args[n+2:]...)
-	cmd.Env = append(ci.env, newEnv...)
-	cmd.Stdin = ci.in
-	cmd.Stdout = ci.out
-	cmd.Stderr = ci.err
+	cmd := ci.command(ctx, ci.args[n+1], ci.args[n+2:]...)
+	env := append([]string(nil), ci.environ()...)
+	cmd.SetEnv(append(env, newEnv...))
+	cmd.SetStdin(ci.in)
+	cmd.SetStdout(ci.out)
+	cmd.SetStderr(ci.err)
 	return cmd.Run()
 }
 
@@ -194,11 +399,34 @@ func execCmd(ctx context.Context, ci info) error {
 	if len(ci.args) == 0 {
 		return errors.New("1 or more arguments required")
 	}
-	name, err := exec.LookPath(ci.args[0])
+	cmd := ci.command(ctx, ci.args[0], ci.args[1:]...)
+	c, ok := cmd.(*externalCmd)
+	if !ok {
+		// Builtins and functions cannot replace the shell; run them and
+		// exit with their status instead.
+		cmd.SetStdin(ci.in)
+		cmd.SetStdout(ci.out)
+		cmd.SetStderr(ci.err)
+		cmd.SetEnv(ci.env)
+		err := cmd.Run()
+		if err != nil && !isExitError(err) && !isExit(err) {
+			fmt.Fprintln(ci.err, err)
+		}
+		return &exitRequest{code: exitStatus(err)}
+	}
+	path, err := exec.LookPath(ci.args[0])
 	if err != nil {
 		return err
 	}
-	return syscall.Exec(name, append([]string{name}, ci.args[1:]...), ci.env)
+	return syscall.Exec(path, c.Args, ci.environ())
+}
+
+// environ returns the environment given to the command.
+func (ci info) environ() []string {
+	if ci.env != nil {
+		return ci.env
+	}
+	return os.Environ()
 }
 
 type execution struct {
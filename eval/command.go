@@ -25,10 +25,33 @@ type Cmd interface {
 
 var _ Cmd = (*externalCmd)(nil)
 
+// CommandContext returns the command named name, which is an alias, a
+// function, a builtin command or an external command in order of precedence.
 func (e *Evaluator) CommandContext(ctx context.Context, name string, arg ...string) Cmd {
-	if x, ok := aliases[name]; ok {
-		name = x.cmd
-		arg = append(x.args, arg...)
+	if _, ok := e.aliases.get(name); ok {
+		return &builtinCmd{
+			ctx:  ctx,
+			fn:   e.runAlias(name),
+			name: name,
+			args: arg,
+			e:    e,
+			env:  e.env,
+		}
+	}
+	return e.command(ctx, name, arg...)
+}
+
+// command is like CommandContext, but does not expand aliases.
+func (e *Evaluator) command(ctx context.Context, name string, arg ...string) Cmd {
+	if body, ok := e.funcs.get(name); ok {
+		return &builtinCmd{
+			ctx:  ctx,
+			fn:   e.call(body),
+			name: name,
+			args: arg,
+			e:    e,
+			env:  e.env,
+		}
 	}
 	if fn, ok := builtins[name]; ok {
 		return &builtinCmd{
@@ -37,25 +60,18 @@ func (e *Evaluator) CommandContext(ctx context.Context, name string, arg ...stri
 			name: name,
 			args: arg,
 			e:    e,
-			env:  os.Environ(),
+			env:  e.env,
 		}
 	}
-	return &externalCmd{exec.Command(name, arg...)}
-
+	cmd := exec.Command(name, arg...)
+	cmd.Env = e.env
+	return &externalCmd{cmd}
 }
 
 type externalCmd struct {
 	*exec.Cmd
 }
 
-func (c *externalCmd) Run() error {
-	err := c.Cmd.Run()
-	if err == nil || isInterrupt(err) {
-		return nil
-	}
-	return err
-}
-
 func (c *externalCmd) SetStderr(w io.Writer) {
 	c.Stderr = w
 }
@@ -96,21 +112,27 @@ func (c *builtinCmd) Run() error {
 }
 
 func (c *builtinCmd) Start() error {
-	c.ch = make(chan error)
+	c.ch = make(chan error, 1)
+	ci := info{
+		stream: stream{
+			in:  c.in,
+			out: c.out,
+			err: c.err,
+		},
+		env:      c.env,
+		command:  c.e.CommandContext,
+		evalFile: c.e.evalFile,
+		status:   c.e.status,
+		jobs:     c.e.jobs,
+		vars:     c.e.vars,
+		aliases:  c.e.aliases,
+		args:     c.args,
+		db:       c.e.db,
+	}
 	go func() {
-		err := c.fn(c.ctx, info{
-			stream: stream{
-				in:  c.in,
-				out: c.out,
-				err: c.err,
-			},
-			env:    c.env,
-			exitCh: c.e.ExitCh,
-			args:   c.args,
-			db:     c.e.db,
-		})
-		c.ch <- err
+		err := c.fn(c.ctx, ci)
 		c.closeDescriptors(c.closeAfterStart)
+		c.ch <- err
 	}()
 	return nil
 }
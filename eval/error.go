@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/elpinal/coco3/token"
+)
+
+// An Error is an error which occurs while evaluating the source at Pos.
+type Error struct {
+	Pos token.Pos
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// ErrorPos returns the position where err occurred, or token.NoPos if
+// unknown.
+func ErrorPos(err error) token.Pos {
+	for err != nil {
+		switch x := err.(type) {
+		case *Error:
+			return x.Pos
+		case *aliasError:
+			// Positions in an alias body are not in the source.
+			return token.NoPos
+		case interface{ Cause() error }:
+			err = x.Cause()
+		default:
+			return token.NoPos
+		}
+	}
+	return token.NoPos
+}
+
+// locate returns err with the position pos, unless pos is invalid, err
+// already has a position or err only tells the exit status of a command.
+func locate(pos token.Pos, err error) error {
+	if err == nil || !pos.IsValid() || isExitError(err) || isExit(err) || ErrorPos(err).IsValid() {
+		return err
+	}
+	return &Error{Pos: pos, Err: err}
+}
+
+// A commandError is an error of a command which could not run or failed
+// while running, e.g. a built-in command or a command not found. Where the
+// exit status of the command is tested, as by && and ||, it is reported and
+// regarded as the exit status.
+type commandError struct {
+	err error
+}
+
+func (e *commandError) Error() string {
+	return e.err.Error()
+}
+
+func (e *commandError) Cause() error {
+	return e.err
+}
+
+// failed returns err as a commandError unless it only tells the exit status
+// or the shell exits.
+func failed(err error) error {
+	if err == nil || isExitError(err) || isExit(err) {
+		return err
+	}
+	return &commandError{err: err}
+}
+
+// isCommandError reports whether err is a commandError or caused by one.
+func isCommandError(err error) bool {
+	for err != nil {
+		if _, ok := err.(*commandError); ok {
+			return true
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = c.Cause()
+	}
+	return false
+}
+
+// A statusError tells the exit status of a command whose error has already
+// been reported.
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("exit status %d", e.status)
+}
+
+// An exitRequest is returned by the exit built-in command. It stops the
+// evaluation, unwinding loops and function calls, and the shell exits with
+// code.
+type exitRequest struct {
+	code int
+}
+
+func (e *exitRequest) Error() string {
+	return fmt.Sprintf("exit %d", e.code)
+}
+
+// isExit reports whether err is caused by the exit built-in command.
+func isExit(err error) bool {
+	_, ok := errors.Cause(err).(*exitRequest)
+	return ok
+}
+
+// An aliasError is an error which occurs while expanding an alias.
+type aliasError struct {
+	name string
+	err  error
+}
+
+func (e *aliasError) Error() string {
+	return "alias " + e.name + ": " + e.err.Error()
+}
+
+func (e *aliasError) Cause() error {
+	return e.err
+}
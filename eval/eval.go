@@ -1,13 +1,17 @@
 package eval
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"os/signal"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/jmoiron/sqlx"
@@ -19,22 +23,45 @@ import (
 
 func New(in io.Reader, out, err io.Writer, db *sqlx.DB) *Evaluator {
 	return &Evaluator{
-		in:     in,
-		out:    out,
-		err:    err,
-		db:     db,
-		ExitCh: make(chan int, 1),
+		in:      in,
+		out:     out,
+		err:     err,
+		db:      db,
+		jobs:    NewJobTable(),
+		vars:    NewVars(),
+		funcs:   NewFuncs(),
+		aliases: NewAliases(),
+		fset:    token.NewFileSet(),
 	}
 }
 
+// Eval evaluates stmts in order and records the exit status of each.
+// A command exiting with a non-zero status does not stop the evaluation.
+// The exit built-in command stops it; then Exited reports true.
 func (e *Evaluator) Eval(stmts []ast.Stmt) error {
+	err := e.evalList(stmts)
+	if isExit(err) {
+		e.exited = true
+		return nil
+	}
+	if isExitError(err) {
+		return nil
+	}
+	return err
+}
+
+// evalList evaluates stmts in order like Eval, but returns the error of the
+// last statement even if it only tells the exit status.
+func (e *Evaluator) evalList(stmts []ast.Stmt) error {
+	var err error
 	for _, stmt := range stmts {
-		err := e.eval(stmt)
-		if err != nil {
+		err = e.eval(stmt)
+		e.status = exitStatus(err)
+		if err != nil && !isExitError(err) {
 			return err
 		}
 	}
-	return nil
+	return err
 }
 
 type Evaluator struct {
@@ -44,12 +71,95 @@ type Evaluator struct {
 
 	db *sqlx.DB
 
-	closeAfterStart []io.Closer
+	status int
+
+	jobs    *JobTable
+	vars    *Vars
+	funcs   *Funcs
+	aliases *Aliases
+
+	// env is the environment given to commands; nil means the environment
+	// of the process. It is set while a function runs under 'let'.
+	env []string
+
+	// args holds the positional parameters.
+	args []string
+
+	// fset holds the positions of the files evaluated by source.
+	fset *token.FileSet
+
+	// depth is the nesting level of function calls.
+	depth int
+
+	// handleError reports the errors which do not stop the evaluation; nil
+	// means writing them to err.
+	handleError func(error)
+
+	// exited is true if the exit built-in command has stopped Eval.
+	exited bool
+}
+
+// Exited reports whether the exit built-in command was run by Eval. Then
+// Status returns the exit code.
+func (e *Evaluator) Exited() bool {
+	return e.exited
+}
+
+// Status returns the exit status of the last statement evaluated.
+func (e *Evaluator) Status() int {
+	return e.status
+}
+
+// SetJobs sets the table which records the jobs started by e.
+func (e *Evaluator) SetJobs(jobs *JobTable) {
+	e.jobs = jobs
+}
+
+// SetVars sets the store of the shell variables used by e.
+func (e *Evaluator) SetVars(vars *Vars) {
+	e.vars = vars
+}
+
+// SetFuncs sets the store of the functions used by e.
+func (e *Evaluator) SetFuncs(funcs *Funcs) {
+	e.funcs = funcs
+}
 
-	ExitCh chan int
+// SetAliases sets the store of the aliases used by e.
+func (e *Evaluator) SetAliases(aliases *Aliases) {
+	e.aliases = aliases
 }
 
-func (e *Evaluator) eval(stmt ast.Stmt) error {
+// SetArgs sets the positional parameters.
+func (e *Evaluator) SetArgs(args []string) {
+	e.args = args
+}
+
+// SetFileSet sets the file set to which the files evaluated by source are
+// added.
+func (e *Evaluator) SetFileSet(fset *token.FileSet) {
+	e.fset = fset
+}
+
+// SetErrorHandler sets the function which reports the errors of the commands
+// which do not stop the evaluation, e.g. a failing command on the left of ||.
+// By default, they are written to the standard error.
+func (e *Evaluator) SetErrorHandler(f func(error)) {
+	e.handleError = f
+}
+
+// SetStatus sets the exit status which is regarded as the one of the last
+// statement, e.g. when carrying it over from another Evaluator.
+func (e *Evaluator) SetStatus(status int) {
+	e.status = status
+}
+
+func (e *Evaluator) eval(stmt ast.Stmt) (err error) {
+	defer func() {
+		if err != nil {
+			err = locate(stmt.Pos(), err)
+		}
+	}()
 	ctx, cancel := context.WithCancel(context.Background())
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
@@ -61,43 +171,168 @@ func (e *Evaluator) eval(stmt ast.Stmt) error {
 	}()
 	switch x := stmt.(type) {
 	case *ast.PipeStmt:
-		commands := make([][]string, 0, len(x.Args))
-		for _, c := range x.Args {
-			args := make([]string, 0, len(c.Args))
-			for _, arg := range c.Args {
-				s, err := e.evalExpr(arg)
-				if err != nil {
-					return err
-				}
-				args = append(args, s...)
-			}
-			if len(args) == 0 {
-				return errors.New("no command to execute")
-			}
-			commands = append(commands, args)
+		commands, err := e.evalPipe(x)
+		if err != nil {
+			return err
 		}
-		return e.execPipe(ctx, commands)
+		return failed(e.execPipe(ctx, commands))
 	case *ast.ExecStmt:
-		args := make([]string, 0, len(x.Args))
-		for _, arg := range x.Args {
-			s, err := e.evalExpr(arg)
-			if err != nil {
+		args, redirs, err := e.evalExec(x)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			// Redirections without command only create or open files.
+			closers, err := applyRedirects(redirs, &stream{})
+			closeAll(closers)
+			return failed(err)
+		}
+		return failed(e.execCmd(ctx, args[0], args[1:], redirs...))
+	case *ast.AndOrStmt:
+		err := e.test(x.X)
+		if err != nil && !isExitError(err) {
+			return err
+		}
+		if (e.status == 0) == (x.Op == token.LAND) {
+			return e.eval(x.Y)
+		}
+		return err
+	case *ast.BgStmt:
+		return e.startJob(x.X)
+	case *ast.AssignStmt:
+		value, err := e.evalExpr(x.Value)
+		if err != nil {
+			return err
+		}
+		if _, ok := x.Value.(*ast.ParenExpr); !ok {
+			value = []string{strings.Join(value, " ")}
+		}
+		return e.vars.Set(x.Name.Name, value)
+	case *ast.FuncStmt:
+		e.funcs.set(x.Name.Name, x.Body)
+		return nil
+	case *ast.BlockStmt:
+		return e.evalList(x.List)
+	case *ast.IfStmt:
+		ok, err := e.evalCond(x.Cond)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return e.evalList(x.Body.List)
+		}
+		if x.Else != nil {
+			return e.eval(x.Else)
+		}
+		return nil
+	case *ast.WhileStmt:
+		var err error
+		for {
+			ok, cerr := e.evalCond(x.Cond)
+			if cerr != nil {
+				return cerr
+			}
+			if !ok {
+				return err
+			}
+			err = e.evalList(x.Body.List)
+			if err != nil && !isExitError(err) || interrupted(err) {
 				return err
 			}
-			args = append(args, s...)
 		}
-		if len(args) == 0 {
-			return nil
+	case *ast.ForStmt:
+		words, err := e.evalExpr(x.List)
+		if err != nil {
+			return err
 		}
-		return e.execCmd(ctx, args[0], args[1:])
+		for _, w := range words {
+			if err := e.vars.Set(x.Var.Name, []string{w}); err != nil {
+				return err
+			}
+			err = e.evalList(x.Body.List)
+			if err != nil && !isExitError(err) || interrupted(err) {
+				return err
+			}
+		}
+		return err
 	}
 	return fmt.Errorf("unexpected type: %T", stmt)
 }
 
+// test evaluates stmt whose exit status is tested, and sets the status. The
+// errors of the commands which fail are reported, and regarded as their exit
+// status.
+func (e *Evaluator) test(stmt ast.Stmt) error {
+	err := e.eval(stmt)
+	if isCommandError(err) {
+		e.report(err)
+		err = &statusError{status: exitStatus(err)}
+	}
+	e.status = exitStatus(err)
+	return err
+}
+
+// report reports err, which does not stop the evaluation.
+func (e *Evaluator) report(err error) {
+	if e.handleError != nil {
+		e.handleError(err)
+		return
+	}
+	fmt.Fprintln(e.err, err)
+}
+
+// evalCond evaluates the condition of an if or while statement, and reports
+// whether it exits with status 0. A failing command is false.
+func (e *Evaluator) evalCond(cond ast.Stmt) (bool, error) {
+	err := e.test(cond)
+	if err != nil && !isExitError(err) || interrupted(err) {
+		return false, err
+	}
+	return e.status == 0, nil
+}
+
+// interrupted reports whether err tells that a command is killed by an
+// interrupt, which stops loops.
+func interrupted(err error) bool {
+	return err != nil && exitStatus(err) == 128+int(syscall.SIGINT)
+}
+
 func (e *Evaluator) evalExpr(expr ast.Expr) ([]string, error) {
 	switch x := expr.(type) {
 	case *ast.Ident:
-		return []string{strings.Replace(x.Name, "~", os.Getenv("HOME"), -1)}, nil
+		word := strings.Replace(x.Name, "~", os.Getenv("HOME"), -1)
+		return expandGlob(word, word), nil
+	case *ast.VarExpr:
+		v := e.lookupVar(x.Name)
+		if len(v) == 1 && v[0] == "" {
+			// An empty variable on its own expands to no words.
+			return nil, nil
+		}
+		return v, nil
+	case *ast.WordExpr:
+		// Only the identifiers in a word are regarded as patterns.
+		var word, pattern string
+		for _, part := range x.Parts {
+			if id, ok := part.(*ast.Ident); ok {
+				s := strings.Replace(id.Name, "~", os.Getenv("HOME"), -1)
+				word += s
+				pattern += s
+				continue
+			}
+			s, err := e.evalExpr(part)
+			if err != nil {
+				return nil, err
+			}
+			word += strings.Join(s, " ")
+			pattern += quoteMeta(strings.Join(s, " "))
+		}
+		return expandGlob(word, pattern), nil
+	case *ast.SubstExpr:
+		out, err := e.substitute(x.Stmts)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Fields(out), nil
 	case *ast.BasicLit:
 		s := strings.TrimPrefix(x.Value, "'")
 		s = strings.TrimSuffix(s, "'")
@@ -114,53 +349,140 @@ func (e *Evaluator) evalExpr(expr ast.Expr) ([]string, error) {
 		}
 		return list, nil
 	case *ast.UnaryExpr:
-		s, err := e.evalExpr(x.X)
+		return nil, fmt.Errorf("unexpected redirection: %v", x.Op)
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected type: %T", expr)
+}
+
+// substitute evaluates stmts and returns their standard output.
+func (e *Evaluator) substitute(stmts []ast.Stmt) (string, error) {
+	var buf bytes.Buffer
+	sub := New(e.in, &buf, e.err, e.db)
+	sub.status = e.status
+	sub.jobs = e.jobs
+	sub.vars = e.vars
+	sub.funcs = e.funcs
+	sub.aliases = e.aliases
+	sub.env = e.env
+	sub.args = e.args
+	sub.fset = e.fset
+	sub.depth = e.depth
+	sub.handleError = e.handleError
+	if err := sub.Eval(stmts); err != nil {
+		return "", errors.Wrap(err, "command substitution")
+	}
+	return buf.String(), nil
+}
+
+// lookupVar returns the value of the variable named name.
+func (e *Evaluator) lookupVar(name string) []string {
+	switch name {
+	case "?":
+		return []string{strconv.Itoa(e.status)}
+	case "#":
+		return []string{strconv.Itoa(len(e.args))}
+	case "@":
+		return e.args
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if 0 < n && n <= len(e.args) {
+			return []string{e.args[n-1]}
+		}
+		return nil
+	}
+	if e.env == nil {
+		v, _ := e.vars.Get(name)
+		return v
+	}
+	if v, ok := e.vars.lookup(name); ok {
+		return v
+	}
+	if s, ok := lookupEnv(e.env, name); ok {
+		return []string{s}
+	}
+	return nil
+}
+
+// A command is a command line evaluated into words and redirections.
+type command struct {
+	args   []string
+	redirs []redirect
+}
+
+// evalExec evaluates x into the arguments and the redirections of a
+// command, expanding aliases.
+func (e *Evaluator) evalExec(x *ast.ExecStmt) ([]string, []redirect, error) {
+	args, redirs, err := e.evalCommand(x)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e.expandAlias(args, redirs)
+}
+
+// evalCommand is like evalExec, but does not expand aliases.
+func (e *Evaluator) evalCommand(x *ast.ExecStmt) ([]string, []redirect, error) {
+	args := make([]string, 0, len(x.Args))
+	for _, expr := range x.Args {
+		s, err := e.evalExpr(expr)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		if len(s) == 0 {
-			return nil, fmt.Errorf("cannot redirect")
+		args = append(args, s...)
+	}
+	redirs := make([]redirect, 0, len(x.Redirs))
+	for _, r := range x.Redirs {
+		redir, err := e.evalRedirect(r)
+		if err != nil {
+			return nil, nil, err
 		}
-		if len(s) > 1 {
-			return nil, fmt.Errorf("cannot redirect to multi-word filename")
+		redirs = append(redirs, redir)
+	}
+	return args, redirs, nil
+}
+
+func (e *Evaluator) evalPipe(x *ast.PipeStmt) ([]command, error) {
+	commands := make([]command, 0, len(x.Args))
+	for _, c := range x.Args {
+		args, redirs, err := e.evalExec(c)
+		if err != nil {
+			return nil, err
 		}
-		switch x.Op {
-		case token.REDIRIN:
-			f, err := os.Open(s[0])
-			if err != nil {
-				return nil, err
-			}
-			e.in = f
-			e.closeAfterStart = append(e.closeAfterStart, f)
-		case token.REDIROUT:
-			f, err := os.Create(s[0])
-			if err != nil {
-				return nil, err
-			}
-			e.out = f
-			e.closeAfterStart = append(e.closeAfterStart, f)
+		if len(args) == 0 {
+			return nil, errors.New("no command to execute")
 		}
-		return nil, nil
-	case nil:
-		return nil, nil
+		commands = append(commands, command{args: args, redirs: redirs})
 	}
-	return nil, fmt.Errorf("unexpected type: %T", expr)
+	return commands, nil
 }
 
-func (e *Evaluator) execCmd(ctx context.Context, name string, args []string) error {
-	cmd := e.CommandContext(ctx, name, args...)
-	cmd.SetStdin(e.in)
-	cmd.SetStdout(e.out)
-	cmd.SetStderr(e.err)
-	return e.run(cmd)
+func (e *Evaluator) execCmd(ctx context.Context, name string, args []string, redirs ...redirect) error {
+	commands := []command{{args: append([]string{name}, args...), redirs: redirs}}
+	p, err := e.makePipe(ctx, commands, e.in)
+	if err != nil {
+		return err
+	}
+	return e.runForeground(p, commandLine(commands))
 }
 
-func (e *Evaluator) execPipe(ctx context.Context, commands [][]string) error {
-	cmds, err := e.makePipe(ctx, commands)
+func (e *Evaluator) execPipe(ctx context.Context, commands []command) error {
+	p, err := e.makePipe(ctx, commands, e.in)
 	if err != nil {
 		return errors.Wrap(err, "constructing pipe")
 	}
-	return e.run(cmds)
+	return e.runForeground(p, commandLine(commands))
+}
+
+// runForeground runs p in the foreground. If job control is enabled, p is
+// given the terminal and recorded as a job when it is stopped.
+func (e *Evaluator) runForeground(p pipeCmd, line string) error {
+	if e.jobs.term == nil {
+		return e.run(p)
+	}
+	j := e.jobs.newJob(line)
+	j.run(p, true)
+	return e.jobs.foreground(j, e.err)
 }
 
 type runner interface {
@@ -168,11 +490,6 @@ type runner interface {
 }
 
 func (e *Evaluator) run(cmd runner) error {
-	defer func() {
-		for _, closer := range e.closeAfterStart {
-			closer.Close()
-		}
-	}()
 	return <-wait(cmd.Run)
 }
 
@@ -184,57 +501,150 @@ func wait(fn func() error) <-chan error {
 	return c
 }
 
-func (e *Evaluator) makePipe(ctx context.Context, commands [][]string) (pipeCmd, error) {
-	cmds := make([]Cmd, len(commands))
+// makePipe makes a pipe of commands, the first of which reads from in.
+func (e *Evaluator) makePipe(ctx context.Context, commands []command, in io.Reader) (p pipeCmd, err error) {
+	n := len(commands)
+	out, errw := sharedStreams(e.out, e.err)
+	p = pipeCmd{
+		cmds:      make([]Cmd, n),
+		pipeEnds:  make([][]io.Closer, n),
+		errStream: errw,
+	}
+	defer func() {
+		if err != nil {
+			p.closeAll()
+		}
+	}()
 	for i, c := range commands {
-		name := c[0]
-		args := c[1:]
-		cmds[i] = e.CommandContext(ctx, name, args...)
-		if i > 0 {
-			pipe, err := cmds[i-1].StdoutPipe()
+		s := stream{in: in, out: out, err: errw}
+		if i < n-1 {
+			pr, pw, err := os.Pipe()
 			if err != nil {
-				return pipeCmd{}, err
+				return p, err
 			}
-			cmds[i].SetStdin(pipe)
+			s.out = pw
+			in = pr
+			p.pipeEnds[i] = append(p.pipeEnds[i], pw)
+			p.pipeEnds[i+1] = append(p.pipeEnds[i+1], pr)
+		}
+		closers, err := applyRedirects(c.redirs, &s)
+		if err != nil {
+			return p, err
 		}
-		cmds[i].SetStderr(e.err)
+		p.closers = append(p.closers, closers...)
+		p.cmds[i] = e.command(ctx, c.args[0], c.args[1:]...)
+		p.cmds[i].SetStdin(s.in)
+		p.cmds[i].SetStdout(s.out)
+		p.cmds[i].SetStderr(s.err)
 	}
-	cmds[0].SetStdin(e.in)
-	cmds[len(cmds)-1].SetStdout(e.out)
-	return pipeCmd{cmds: cmds, errStream: e.err}, nil
+	return p, nil
+}
+
+// sharedStreams returns out and err guarded so that the commands of a pipe
+// can write to them concurrently. Files need no guard.
+func sharedStreams(out, err io.Writer) (io.Writer, io.Writer) {
+	if err == nil {
+		return out, err
+	}
+	if _, ok := err.(*os.File); ok {
+		return out, err
+	}
+	w := &lockedWriter{w: err}
+	if sameWriter(out, err) {
+		return w, w
+	}
+	return out, w
+}
+
+func sameWriter(a, b io.Writer) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
+// A lockedWriter serializes writes to w.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
 }
 
 type pipeCmd struct {
 	cmds      []Cmd
 	errStream io.Writer
+
+	// pipeEnds holds the ends of the pipes used by each command.
+	pipeEnds [][]io.Closer
+
+	// closers holds the files opened by redirections.
+	closers []io.Closer
+
+	// job is the job which the commands belong to if job control is
+	// enabled, and fg reports whether it is in the foreground.
+	job *job
+	fg  bool
 }
 
 func (p pipeCmd) errorf(format string, err error) {
 	fmt.Fprintf(p.errStream, format, err)
 }
 
-func (p pipeCmd) start() error {
-	for _, cmd := range p.cmds {
-		if err := cmd.Start(); err != nil {
-			p.errorf("%v\n", err)
-		}
+func (p pipeCmd) closeAll() {
+	for _, ends := range p.pipeEnds {
+		closeAll(ends)
 	}
-	return nil
+	closeAll(p.closers)
 }
 
-func isInterrupt(err error) bool {
-	ee, ok := err.(*exec.ExitError)
-	if !ok {
-		return false
+// start starts all the commands and returns the errors of the commands which
+// could not be started.
+func (p pipeCmd) start() []error {
+	errs := make([]error, len(p.cmds))
+	for i, cmd := range p.cmds {
+		if b, ok := cmd.(*builtinCmd); ok {
+			// A builtin command runs in this process, so the pipes must
+			// be kept open until it finishes.
+			b.closeAfterStart = append(b.closeAfterStart, p.pipeEnds[i]...)
+			errs[i] = b.Start()
+			continue
+		}
+		if c, ok := cmd.(*externalCmd); ok && p.job != nil {
+			errs[i] = p.job.startProcess(c.Cmd, p.fg)
+		} else {
+			errs[i] = cmd.Start()
+		}
+		// Close the ends in this process so that the other end sees
+		// EOF or EPIPE when the command exits.
+		closeAll(p.pipeEnds[i])
 	}
-	status := ee.ProcessState.Sys().(syscall.WaitStatus)
-	return status.Signal() == syscall.SIGINT
+	return errs
 }
 
-func (p pipeCmd) wait() error {
-	for _, cmd := range p.cmds {
-		err := cmd.Wait()
-		if err == nil || isInterrupt(err) {
+// wait waits for the commands which have been started.
+// It reports errors of the commands except the last one, and returns the
+// error of the last one, which determines the exit status of the pipe.
+func (p pipeCmd) wait(errs []error) error {
+	last := len(p.cmds) - 1
+	for i, cmd := range p.cmds {
+		err := errs[i]
+		if err == nil {
+			if c, ok := cmd.(*externalCmd); ok && p.job != nil {
+				p.job.untilExit(c.Process.Pid)
+			}
+			err = cmd.Wait()
+		}
+		if i == last {
+			return err
+		}
+		if err == nil || isExitError(err) || isExit(err) {
+			// exit in a pipe only ends its command.
 			continue
 		}
 		p.errorf("%v\n", err)
@@ -243,9 +653,45 @@ func (p pipeCmd) wait() error {
 }
 
 func (p pipeCmd) Run() error {
-	err := p.start()
-	if err != nil {
-		return err
+	defer closeAll(p.closers)
+	return p.wait(p.start())
+}
+
+// isExitError reports whether err only tells the exit status of a command,
+// including that of a stopped one.
+func isExitError(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case *exec.ExitError, *statusError:
+		return true
+	default:
+		return cause == errStopped
+	}
+}
+
+// exitStatus returns the exit status corresponding to err, which is returned
+// by running a command.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if errors.Cause(err) == errStopped {
+		return stoppedStatus
+	}
+	switch x := errors.Cause(err).(type) {
+	case *statusError:
+		return x.status
+	case *exitRequest:
+		return x.code
+	case *exec.ExitError:
+		status := x.Sys().(syscall.WaitStatus)
+		if status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return status.ExitStatus()
+	case *exec.Error:
+		if x.Err == exec.ErrNotFound {
+			return 127
+		}
 	}
-	return p.wait()
+	return 1
 }
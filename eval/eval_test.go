@@ -4,10 +4,49 @@ import (
 	"bytes"
 	"context"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/elpinal/coco3/ast"
+	"github.com/elpinal/coco3/parser"
+	"github.com/elpinal/coco3/token"
 )
 
+// runTest evaluates src and checks that it writes out to the standard output
+// and ends with status. Eval may fail only if status is non-zero. It returns
+// the Evaluator and what was written to the standard error.
+func runTest(t *testing.T, src, out string, status int) (*Evaluator, string) {
+	t.Helper()
+	f, err := parser.ParseSrc([]byte(src))
+	if err != nil {
+		t.Fatalf("parsing %q: %v", src, err)
+	}
+	var stdout syncBuffer
+	var stderr bytes.Buffer
+	e := New(nil, &stdout, &stderr, nil)
+	done := make(chan error, 1)
+	go func() {
+		done <- e.Eval(f.Lines)
+	}()
+	select {
+	case err := <-done:
+		if err != nil && status == 0 {
+			t.Errorf("Eval(%q): %v", src, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Eval(%q): does not stop", src)
+	}
+	if got := stdout.String(); got != out {
+		t.Errorf("Eval(%q): output: got %q, want %q", src, got, out)
+	}
+	if got := e.Status(); got != status {
+		t.Errorf("Eval(%q): status: got %d, want %d", src, got, status)
+	}
+	return e, stderr.String()
+}
+
 func TestExecCmd(t *testing.T) {
 	var out, err bytes.Buffer
 	e := New(nil, &out, &err, nil)
@@ -25,16 +64,9 @@ func TestExecCmd(t *testing.T) {
 func TestExecPipe(t *testing.T) {
 	var out, err bytes.Buffer
 	e := New(nil, &out, &err, nil)
-	if err := e.execPipe(context.Background(), [][]string{
-		{
-			"echo",
-			"aaa",
-		},
-		{
-			"tr",
-			"a",
-			"A",
-		},
+	if err := e.execPipe(context.Background(), []command{
+		{args: []string{"echo", "aaa"}},
+		{args: []string{"tr", "a", "A"}},
 	}); err != nil {
 		t.Errorf("execute pipe: %v", err)
 	}
@@ -65,3 +97,513 @@ func TestKillBuiltin(t *testing.T) {
 		t.Errorf("echo: should be killed by 1 second, but elapsed time is %v", elapsed)
 	}
 }
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{"true", 0},
+		{"false", 1},
+		{"false; true", 0},
+		{"true; false", 1},
+		{"true | false", 1},
+		{"false | true", 0},
+		{"sh -c 'exit 3'", 3},
+		{"echo a | sh -c 'exit 4'", 4},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, "", test.want)
+	}
+}
+
+func TestStatusNotFound(t *testing.T) {
+	e := New(nil, ioutil.Discard, ioutil.Discard, nil)
+	err := e.Eval([]ast.Stmt{&ast.ExecStmt{Args: []ast.Expr{&ast.Ident{Name: "coco3-no-such-command"}}}})
+	if err == nil {
+		t.Error("Eval: should fail")
+	}
+	if got, want := e.Status(), 127; got != want {
+		t.Errorf("status: got %d, want %d", got, want)
+	}
+}
+
+func TestAndOr(t *testing.T) {
+	tests := []struct {
+		src    string
+		out    string
+		status int
+	}{
+		{"true && echo a", "a\n", 0},
+		{"false && echo a", "", 1},
+		{"true || echo a", "", 0},
+		{"false || echo a", "a\n", 0},
+		{"false && echo a || echo b", "b\n", 0},
+		{"true && echo a || echo b", "a\n", 0},
+		{"true && false || echo b", "b\n", 0},
+		{"false || false", "", 1},
+		{"echo a | tr a b && echo c", "b\nc\n", 0},
+		{"true &&\necho a", "a\n", 0},
+		{"echo a&b", "a&b\n", 0},
+		{"cd /nonexistent || echo fallback", "fallback\n", 0},
+		{"cd /nonexistent && echo a", "", 1},
+		{"coco3-no-such-command || echo $?", "127\n", 0},
+		{"cd /nonexistent || false", "", 1},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, test.status)
+	}
+}
+
+func TestAndOrReport(t *testing.T) {
+	f, err := parser.ParseSrc([]byte("cd /nonexistent || echo a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut bytes.Buffer
+	var reported []error
+	e := New(nil, &out, &errOut, nil)
+	if err := e.Eval(f.Lines); err != nil {
+		t.Errorf("Eval: %v", err)
+	}
+	if errOut.Len() == 0 {
+		t.Error("the error of cd is not written to the standard error")
+	}
+	e.SetErrorHandler(func(err error) { reported = append(reported, err) })
+	errOut.Reset()
+	if err := e.Eval(f.Lines); err != nil {
+		t.Errorf("Eval: %v", err)
+	}
+	if len(reported) != 1 || ErrorPos(reported[0]) != f.Lines[0].(*ast.AndOrStmt).X.Pos() {
+		t.Errorf("reported errors: got %v, want the error of cd", reported)
+	}
+	if got := errOut.String(); got != "" {
+		t.Errorf("error output: got %q, want %q", got, "")
+	}
+}
+
+func TestVar(t *testing.T) {
+	os.Setenv("COCO3_TEST_VAR", "x y")
+	defer os.Unsetenv("COCO3_TEST_VAR")
+	os.Unsetenv("COCO3_TEST_UNSET")
+	tests := []struct {
+		src string
+		out string
+	}{
+		{"echo $COCO3_TEST_VAR", "x y\n"},
+		{"echo ${COCO3_TEST_VAR}", "x y\n"},
+		{"echo a$COCO3_TEST_VAR/bin", "ax y/bin\n"},
+		{"echo ${COCO3_TEST_VAR}z", "x yz\n"},
+		{"echo '$COCO3_TEST_VAR'", "$COCO3_TEST_VAR\n"},
+		{"echo a $COCO3_TEST_UNSET b", "a b\n"},
+		{"echo a${COCO3_TEST_UNSET}b", "ab\n"},
+		{"echo $ a$", "$ a$\n"},
+		{"false; echo $?", "1\n"},
+		{"echo ($COCO3_TEST_VAR b)", "x y b\n"},
+		{"setenv COCO3_TEST_UNSET v; echo $COCO3_TEST_UNSET", "v\n"},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, 0)
+	}
+	os.Unsetenv("COCO3_TEST_UNSET")
+}
+
+func TestSubst(t *testing.T) {
+	tests := []struct {
+		src string
+		out string
+	}{
+		{"echo $(echo a)", "a\n"},
+		{"echo x $(echo a b; echo c) y", "x a b c y\n"},
+		{"echo $(printf 'a\\nb\\n' | tr a A)", "A b\n"},
+		{"echo a$(echo b)c", "abc\n"},
+		{"echo $(echo $(echo nested))", "nested\n"},
+		{"echo $(true)", "\n"},
+		{"echo $(false && echo a || echo b)", "b\n"},
+		{"echo $(\necho a\necho b\n)", "a b\n"},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, 0)
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	tests := []struct {
+		src    string
+		out    string
+		status int
+	}{
+		{"if true { echo a }", "a\n", 0},
+		{"if false { echo a }", "", 0},
+		{"if false { echo a } else { echo b }", "b\n", 0},
+		{"if false { echo a } else if true { echo b } else { echo c }", "b\n", 0},
+		{"if false { echo a } else if false { echo b } else { echo c }", "c\n", 0},
+		{"if true && false { echo a } else { false }", "", 1},
+		{"if echo a | grep -q a {\n\techo b\n\tfalse\n}", "b\n", 1},
+		{"if true {\n\tif false {\n\t\techo a\n\t} else {\n\t\techo b\n\t}\n}\necho c", "b\nc\n", 0},
+		{"for x in (a b c) { echo $x }", "a\nb\nc\n", 0},
+		{"for x in (a\n'b c'\n$(echo d e)) {\n\techo $x\n}", "a\nb c\nd\ne\n", 0},
+		{"for x in () { echo a }", "", 0},
+		{"xs=(a 'b c'); for x in $xs { echo $x }", "a\nb c\n", 0},
+		{"for x in $(echo a b) { echo $x }", "a\nb\n", 0},
+		{"for x in a { echo $x }", "a\n", 0},
+		{"for x in 'a b' { echo $x }", "a b\n", 0},
+		{"for x in (a b) { false }", "", 1},
+		{"for x in (a b) { for y in (1 2) { echo $x$y } }", "a1\na2\nb1\nb2\n", 0},
+		{"while false { echo a }", "", 0},
+		{"while ls /nonexistent 2>/dev/null { echo a }; echo $?", "0\n", 0},
+		{"echo '{' a{b} {} '}'", "{ a{b} {} }\n", 0},
+		{"if cd /nonexistent { echo a } else { echo b }", "b\n", 0},
+		{"if coco3-no-such-command { echo a }; echo $?", "0\n", 0},
+		{"while cd /nonexistent { echo a }; echo c", "c\n", 0},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, test.status)
+	}
+}
+
+func TestWhile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coco3-while")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "f")
+	src := "while test ! -e " + file + " { echo a; touch " + file + " }"
+	f, err := parser.ParseSrc([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	e := New(nil, &out, ioutil.Discard, nil)
+	if err := e.Eval(f.Lines); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "a\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestShellVars(t *testing.T) {
+	tests := []struct {
+		src string
+		out string
+	}{
+		{"x=a; echo $x", "a\n"},
+		{"x=a; x=b; echo $x", "b\n"},
+		{"x='a b'; echo $x", "a b\n"},
+		{"x=; echo a $x b", "a b\n"},
+		{"x=a; y=$x/b$(echo c); echo $y", "a/bc\n"},
+		{"xs=(a 'b c' d); for x in ($xs) { echo $x }", "a\nb c\nd\n"},
+		{"xs=(a b); echo $xs.txt", "a b.txt\n"},
+		{"xs=(); echo a $xs b", "a b\n"},
+		{"x=a; unset x; echo a $x b", "a b\n"},
+		{"echo x=a", "x=a\n"},
+		{"x=a; sh -c 'echo ${x-unset}'", "unset\n"},
+		{"x=a; export x; sh -c 'echo $x'; unset x", "a\n"},
+		{"export x=b; x=c; sh -c 'echo $x'; unset x", "c\n"},
+		{"export x=b; export; unset x", "x=b\n"},
+		{"for i in (a b) { true }; echo $i", "b\n"},
+		{"for i in (a b) { true }; sh -c 'echo ${i-unset}'", "unset\n"},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, 0)
+	}
+}
+
+func TestInheritedVars(t *testing.T) {
+	// Variables inherited from the environment are exported.
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	home := os.Getenv("HOME")
+	defer os.Setenv("HOME", home)
+
+	runTest(t, "HOME=/x; sh -c 'echo $HOME'", "/x\n", 0)
+	runTest(t, "PATH=/nonexistent; ls", "", 127)
+}
+
+func TestShellVarsSession(t *testing.T) {
+	// Variables are kept across Evaluators sharing the store.
+	vars := NewVars()
+	var out bytes.Buffer
+	for _, src := range []string{"x=a", "echo $x"} {
+		f, err := parser.ParseSrc([]byte(src))
+		if err != nil {
+			t.Fatalf("parsing %q: %v", src, err)
+		}
+		e := New(nil, &out, ioutil.Discard, nil)
+		e.SetVars(vars)
+		if err := e.Eval(f.Lines); err != nil {
+			t.Errorf("Eval(%q): %v", src, err)
+		}
+	}
+	if got, want := out.String(), "a\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFunc(t *testing.T) {
+	tests := []struct {
+		src    string
+		out    string
+		status int
+	}{
+		{"func f { echo a }; f", "a\n", 0},
+		{"func f { echo $# $1 $2 }; f a 'b c'", "2 a b c\n", 0},
+		{"func f { for x in ($@) { echo $x } }; f a b", "a\nb\n", 0},
+		{"func f { echo ${10} }; f 1 2 3 4 5 6 7 8 9 10", "10\n", 0},
+		{"func f { echo a $3 b }; f 1", "a b\n", 0},
+		{"func f {\n\techo a\n\techo b\n}\nf", "a\nb\n", 0},
+		{"func f { echo $1 }; f a | tr a b", "b\n", 0},
+		{"func f { tr a b }; echo a | f | cat", "b\n", 0},
+		{"func f { sh -c 'exit 3' }; f", "", 3},
+		{"func f { false }; f || echo failed", "failed\n", 0},
+		{"func f { echo $1 }; func f { echo again $1 }; f a", "again a\n", 0},
+		{"func f { x=$1 }; f a; echo $x", "a\n", 0},
+		{"func f { echo $1; g $2 }; func g { echo $1 }; f a b", "a\nb\n", 0},
+		{"func f { echo $(g $1) }; func g { echo g$1 }; f a", "ga\n", 0},
+		{"func f { if test $1 = 0 { echo done } else { f $(expr $1 - 1) } }; f 3", "done\n", 0},
+		{"func echo { status }; false; echo", "1\n", 0},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, test.status)
+	}
+}
+
+func TestFuncRecursionLimit(t *testing.T) {
+	f, err := parser.ParseSrc([]byte("func f { f }; f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(nil, ioutil.Discard, ioutil.Discard, nil)
+	if err := e.Eval(f.Lines); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestLet(t *testing.T) {
+	tests := []struct {
+		src    string
+		out    string
+		status int
+	}{
+		{"let X 1 in sh -c 'echo $X'", "1\n", 0},
+		{"let X 1 Y 2 in sh -c 'echo $X $Y'", "1 2\n", 0},
+		{"alias a 'sh -c ''echo $X'''; let X 1 in a", "1\n", 0},
+		{"func f { sh -c 'echo $X'; echo $X }; let X 1 in f", "1\n1\n", 0},
+		{"func f { let Y 2 in sh -c 'echo $X $Y' }; let X 1 in f", "1 2\n", 0},
+		{"func f { sh -c 'exit 3' }; let X 1 in f", "", 3},
+		{"let X 1 in echo a", "a\n", 0},
+		{"false; let X 1 in status", "1\n", 0},
+		{"let X 1 in sh -c 'echo $X'; sh -c 'echo x$X'", "1\nx\n", 0},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, test.status)
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		src    string
+		out    string
+		exited bool
+		status int
+	}{
+		{"exit 3; echo after", "", true, 3},
+		{"while true { exit 0 }; echo after", "", true, 0},
+		{"for x in (a b) { echo $x; exit 5 }", "a\n", true, 5},
+		{"func f { exit 4; echo x }; f; echo y", "", true, 4},
+		{"if true { exit 8 }; echo z", "", true, 8},
+		{"true && exit 7; echo z", "", true, 7},
+		{"false; exit", "", true, 1},
+		{"exit 2 | cat; echo b", "b\n", false, 0},
+		{"echo $(exit 9) x", "x\n", false, 0},
+	}
+	for _, test := range tests {
+		e, _ := runTest(t, test.src, test.out, test.status)
+		if got := e.Exited(); got != test.exited {
+			t.Errorf("Eval(%q): exited: got %v, want %v", test.src, got, test.exited)
+		}
+	}
+}
+
+func TestExecBuiltin(t *testing.T) {
+	tests := []struct {
+		src  string
+		out  string
+		code int
+	}{
+		{"exec echo a", "a\n", 0},
+		{"func f { echo $1; false }; exec f a", "a\n", 1},
+		{"exec exit 3", "", 3},
+		{"exec echo hi; echo no", "hi\n", 0},
+		{"func f { echo $1 }; exec f a; echo no", "a\n", 0},
+		{"for x in (a b) { exec echo $x }; echo no", "a\n", 0},
+		{"exec cd /nonexistent; echo no", "", 1},
+	}
+	for _, test := range tests {
+		e, _ := runTest(t, test.src, test.out, test.code)
+		if !e.Exited() {
+			t.Errorf("Eval(%q): the shell does not exit", test.src)
+		}
+	}
+}
+
+func TestAlias(t *testing.T) {
+	tests := []struct {
+		src    string
+		out    string
+		status int
+	}{
+		{"alias e 'echo a'; e b", "a b\n", 0},
+		{"alias e 'echo ''a  b'' '''''; e c", "a  b  c\n", 0},
+		{"alias e 'echo (a b)'; e c", "a b c\n", 0},
+		{"alias e 'echo $HOME'; e", os.Getenv("HOME") + "\n", 0},
+		{"alias e 'sh -c ''echo a >&2'' 2>&1'; e | tr a b", "b\n", 0},
+		{"alias tr 'tr a'; echo ab | tr b", "bb\n", 0},
+		{"alias e 'echo a'; alias f 'e b'; f c", "a b c\n", 0},
+		{"alias e 'echo a'; func f { e $1 }; f b", "a b\n", 0},
+		{"alias e 'echo a'; echo $(e b)", "a b\n", 0},
+		{"alias e 'echo a'; unalias e; e", "", 127},
+		{"alias e 'sh -c ''exit 3'''; e", "", 3},
+		{"alias e 'echo a'; alias e", "e\techo a\t(alias command)\n", 0},
+		{"alias f 'echo f'; alias e 'echo e'; alias", "e\techo e\t(alias command)\nf\techo f\t(alias command)\n", 0},
+		{"alias e", "", 1},
+		{"unalias e", "", 1},
+		{"alias e 'echo a; echo b'", "", 1},
+		{"alias e 'echo a | cat'", "", 1},
+		{"alias e ''", "", 1},
+		{"alias 'a b' 'echo a'", "", 1},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, test.status)
+	}
+}
+
+func TestAliasesDefine(t *testing.T) {
+	aliases := NewAliases()
+	if err := aliases.Define("ll", "ls -l", "config"); err != nil {
+		t.Fatal(err)
+	}
+	if err := aliases.Define("bad", "echo 'a", "config"); err == nil {
+		t.Error("expected an error for an unterminated string")
+	}
+	var out bytes.Buffer
+	e := New(nil, &out, ioutil.Discard, nil)
+	e.SetAliases(aliases)
+	f, err := parser.ParseSrc([]byte("alias"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Eval(f.Lines); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "ll\tls -l\t(config)\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestErrorPos(t *testing.T) {
+	tests := []struct {
+		src string
+		pos string
+	}{
+		{"echo a; nosuch", "test:1:9"},
+		{"true &&\n  nosuch", "test:2:3"},
+		{"echo a > /nonexistent/a", "test:1:8"},
+		{"echo $(nosuch)", "test:1:8"},
+		{"if true {\n\techo a | nosuch\n}", "test:2:2"},
+		{"alias e 'cat < /nonexistent'; e", "test:1:31"},
+	}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "test", []byte(test.src))
+		if err != nil {
+			t.Fatalf("parsing %q: %v", test.src, err)
+		}
+		e := New(nil, ioutil.Discard, ioutil.Discard, nil)
+		err = e.Eval(f.Lines)
+		if err == nil {
+			t.Errorf("Eval(%q): should fail", test.src)
+			continue
+		}
+		if got := fset.Position(ErrorPos(err)).String(); got != test.pos {
+			t.Errorf("Eval(%q): position: got %s, want %s", test.src, got, test.pos)
+		}
+	}
+}
+
+func TestComment(t *testing.T) {
+	tests := []struct {
+		src string
+		out string
+	}{
+		{"#!/usr/bin/env coco3\necho a", "a\n"},
+		{"echo a # b", "a\n"},
+		{"echo a#b '#c' $#", "a#b #c 0\n"},
+		{"echo a;# b\necho c", "a\nc\n"},
+		{"if true { # a\n\techo b # c\n} # d", "b\n"},
+		{"cat <<EOF # a\n# b\nEOF", "# b\n"},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, 0)
+	}
+}
+
+func TestSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coco3-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "lib.coco")
+	src := "echo $# $@\nx=set\nfunc f { echo f $1 }\nalias e 'echo e'\nsh -c 'exit 3'\n"
+	if err := ioutil.WriteFile(file, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		src    string
+		out    string
+		status int
+	}{
+		{"source " + file, "0\n", 3},
+		{"source " + file + " a b", "2 a b\n", 3},
+		{"source " + file + "; echo $x; f a; e", "0\nset\nf a\ne\n", 0},
+		{"func g { source " + file + " }; g a", "1 a\n", 3},
+		{"source " + file + " | tr 0 z", "z\n", 0},
+		{"source", "", 1},
+		{"source " + filepath.Join(dir, "nonexistent"), "", 1},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, test.status)
+	}
+}
+
+func TestMultiLinePipe(t *testing.T) {
+	tests := []struct {
+		src string
+		out string
+	}{
+		{"echo ab |\ntr a c", "cb\n"},
+		{"echo ab |\n\n  tr a c |\ntr b d", "cd\n"},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, 0)
+	}
+}
+
+func TestLineContinuation(t *testing.T) {
+	tests := []struct {
+		src string
+		out string
+	}{
+		{"echo a \\\nb", "a b\n"},
+		{"echo a\\\n  b \\\n\tc", "a b c\n"},
+		{"echo a \\\n| tr a b", "b\n"},
+		{"echo a\\b", "a\\b\n"},
+		{"echo 'a \\\nb'", "a \\\nb\n"},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, 0)
+	}
+}
@@ -0,0 +1,87 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/elpinal/coco3/ast"
+	"github.com/elpinal/coco3/parser"
+)
+
+// maxCallDepth is the maximum nesting level of function calls.
+const maxCallDepth = 1000
+
+// Funcs is a store of user-defined functions.
+// It is safe for concurrent use.
+type Funcs struct {
+	mu sync.Mutex
+	m  map[string]*ast.BlockStmt
+}
+
+func NewFuncs() *Funcs {
+	return &Funcs{m: make(map[string]*ast.BlockStmt)}
+}
+
+func (f *Funcs) get(name string) (*ast.BlockStmt, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, ok := f.m[name]
+	return body, ok
+}
+
+func (f *Funcs) set(name string, body *ast.BlockStmt) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.m[name] = body
+}
+
+// call returns a function which calls body like a builtin command. The body
+// is evaluated with the arguments as positional parameters, by an Evaluator
+// sharing the session with e.
+func (e *Evaluator) call(body *ast.BlockStmt) func(context.Context, info) error {
+	return func(_ context.Context, ci info) error {
+		if e.depth >= maxCallDepth {
+			return fmt.Errorf("maximum call depth exceeded: %d", maxCallDepth)
+		}
+		child := e.fork(ci)
+		child.args = ci.args
+		child.depth = e.depth + 1
+		return child.evalList(body.List)
+	}
+}
+
+// evalFile evaluates the file filename like a function body. If args is nil,
+// the positional parameters are inherited.
+func (e *Evaluator) evalFile(ci info, filename string, args []string) error {
+	if e.depth >= maxCallDepth {
+		return fmt.Errorf("maximum call depth exceeded: %d", maxCallDepth)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	f, err := parser.ParseFile(e.fset, filename, b)
+	if err != nil {
+		return err
+	}
+	child := e.fork(ci)
+	if args != nil {
+		child.args = args
+	}
+	child.depth = e.depth + 1
+	return child.evalList(f.Lines)
+}
+
+// fork returns a copy of e which shares the session with e, and runs with the
+// streams, the status and the environment of ci.
+func (e *Evaluator) fork(ci info) *Evaluator {
+	child := *e
+	child.in = ci.in
+	child.out = ci.out
+	child.err = ci.err
+	child.status = ci.status
+	child.env = ci.env
+	return &child
+}
@@ -0,0 +1,67 @@
+package eval
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const globMeta = `*?[\`
+
+// hasMeta reports whether s contains any of the magic characters recognized
+// by filepath.Match.
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, `*?[`)
+}
+
+// quoteMeta escapes the magic characters in s so that s matches itself.
+func quoteMeta(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(globMeta, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// expandGlob returns the names of the files matching pattern.
+// As with POSIX shells, if pattern has no magic characters, is malformed,
+// or matches no files, word, which is the literal form of pattern, is
+// returned as it is.
+func expandGlob(word, pattern string) []string {
+	if !hasMeta(pattern) {
+		return []string{word}
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return []string{word}
+	}
+	list := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if !isHiddenMatch(pattern, m) {
+			list = append(list, m)
+		}
+	}
+	if len(list) == 0 {
+		return []string{word}
+	}
+	return list
+}
+
+// isHiddenMatch reports whether match has a file name beginning with a dot
+// which the corresponding element of pattern does not explicitly begin with.
+// Such files are not matched by wildcards.
+func isHiddenMatch(pattern, match string) bool {
+	ps := strings.Split(pattern, string(filepath.Separator))
+	ms := strings.Split(match, string(filepath.Separator))
+	// Compare from the end because filepath.Glob may clean the leading
+	// directory of pattern.
+	for i, j := len(ps)-1, len(ms)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		p, m := ps[i], ms[j]
+		if strings.HasPrefix(m, ".") && hasMeta(p) && !strings.HasPrefix(p, ".") {
+			return true
+		}
+	}
+	return false
+}
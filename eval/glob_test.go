@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coco3-glob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a.txt", "b.txt", ".h.txt", "c.go", "[x].go", filepath.Join("sub", "d.txt")} {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("COCO3_TEST_DIR", dir)
+	defer os.Unsetenv("COCO3_TEST_DIR")
+
+	tests := []struct {
+		src string
+		out string
+	}{
+		{"echo *.txt", "a.txt b.txt\n"},
+		{"echo ?.go", "c.go\n"},
+		{"echo [ab].txt", "a.txt b.txt\n"},
+		{"echo .*.txt", ".h.txt\n"},
+		{"echo */*.txt", "sub/d.txt\n"},
+		{"echo ./*.go", "[x].go c.go\n"},
+		{"echo *.none", "*.none\n"},
+		{"echo '*.txt'", "*.txt\n"},
+		{"echo [", "[\n"},
+		{"echo $COCO3_TEST_DIR/sub/*", dir + "/sub/d.txt\n"},
+		{"echo (*.go x)", "[x].go c.go x\n"},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, 0)
+	}
+}
+
+func TestQuoteMeta(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coco3-glob[*]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "a")
+	if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got := expandGlob("", quoteMeta(dir)+"/*")
+	if len(got) != 1 || got[0] != name {
+		t.Errorf("expandGlob: got %q, want %q", got, []string{name})
+	}
+}
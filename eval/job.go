@@ -0,0 +1,320 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/elpinal/coco3/ast"
+)
+
+// errStopped is returned when a job in the foreground is stopped.
+var errStopped = errors.New("stopped")
+
+// errNoJobControl is returned by the operations of job control where it is
+// not supported.
+var errNoJobControl = errors.New("job control is not supported")
+
+// A JobTable holds the commands running in the background and the stopped
+// ones. It is safe for concurrent use.
+type JobTable struct {
+	mu   sync.Mutex
+	jobs []*job // in ascending order of ID
+
+	// term is the terminal given to jobs in the foreground; nil if job
+	// control is disabled.
+	term *terminal
+}
+
+func NewJobTable() *JobTable {
+	return &JobTable{}
+}
+
+// A terminal is the controlling terminal of the shell.
+type terminal struct {
+	fd   int
+	pgid int // process group of the shell
+}
+
+// A job is a pipe of commands started by the shell.
+type job struct {
+	id   int    // 0 until added to a job table
+	line string // command line for display
+	term *terminal
+
+	// pgid is the process group of the job if job control is enabled
+	// and the job has an external command; otherwise 0.
+	pgid int
+
+	mu      sync.Mutex
+	stopped bool
+	stop    chan struct{} // notified when the job stops
+
+	done chan struct{}
+	err  error // valid after done is closed
+}
+
+func (j *job) finished() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (j *job) wait() error {
+	<-j.done
+	return j.err
+}
+
+func (j *job) isStopped() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stopped
+}
+
+func (j *job) setStopped(stopped bool) {
+	j.mu.Lock()
+	j.stopped = stopped
+	j.mu.Unlock()
+	if stopped {
+		select {
+		case j.stop <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (j *job) state() string {
+	if !j.finished() {
+		if j.isStopped() {
+			return "Stopped"
+		}
+		return "Running"
+	}
+	if status := exitStatus(j.err); status != 0 {
+		return "Exit " + strconv.Itoa(status)
+	}
+	return "Done"
+}
+
+func (j *job) String() string {
+	return fmt.Sprintf("[%d]  %-10s%s", j.id, j.state(), j.line)
+}
+
+// run starts p as j and waits for it in the background. If fg is true and
+// job control is enabled, p is placed in the foreground of the terminal.
+func (j *job) run(p pipeCmd, fg bool) {
+	if j.term != nil {
+		p.job, p.fg = j, fg
+	}
+	errs := p.start()
+	go func() {
+		j.err = p.wait(errs)
+		closeAll(p.closers)
+		close(j.done)
+	}()
+}
+
+// startProcess starts cmd in the process group of j.
+func (j *job) startProcess(cmd *exec.Cmd, fg bool) error {
+	setProcessGroup(cmd, j.pgid, fg, j.term.fd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if j.pgid == 0 {
+		j.pgid = cmd.Process.Pid
+	}
+	return nil
+}
+
+// untilExit blocks until the process pid exits, recording whether j is
+// stopped meanwhile. The process is left to be reaped by the caller.
+func (j *job) untilExit(pid int) {
+	for {
+		stopped, err := waitStop(pid)
+		if err != nil || !stopped {
+			return
+		}
+		j.setStopped(true)
+		continued, err := waitCont(pid)
+		if err != nil || !continued {
+			return
+		}
+		j.setStopped(false)
+	}
+}
+
+// resume continues j if it is stopped.
+func (j *job) resume() error {
+	if !j.isStopped() {
+		return nil
+	}
+	select {
+	case <-j.stop:
+	default:
+	}
+	j.setStopped(false)
+	return continueGroup(j.pgid)
+}
+
+func (t *JobTable) newJob(line string) *job {
+	return &job{
+		line: line,
+		term: t.term,
+		stop: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+}
+
+// add adds j to t, giving it a new ID.
+func (t *JobTable) add(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.id = 1
+	if n := len(t.jobs); n > 0 {
+		j.id = t.jobs[n-1].id + 1
+	}
+	t.jobs = append(t.jobs, j)
+}
+
+func (t *JobTable) list() []*job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*job(nil), t.jobs...)
+}
+
+func (t *JobTable) remove(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, x := range t.jobs {
+		if x == j {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// lookup returns the job specified by spec, which is a job ID optionally
+// prefixed with "%". If spec is empty, the most recent job is returned.
+func (t *JobTable) lookup(spec string) (*job, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if spec == "" {
+		if len(t.jobs) == 0 {
+			return nil, errors.New("no current job")
+		}
+		return t.jobs[len(t.jobs)-1], nil
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(spec, "%"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid job spec: %q", spec)
+	}
+	i := sort.Search(len(t.jobs), func(i int) bool { return t.jobs[i].id >= id })
+	if i == len(t.jobs) || t.jobs[i].id != id {
+		return nil, fmt.Errorf("no such job: %s", spec)
+	}
+	return t.jobs[i], nil
+}
+
+// foreground waits for j until it finishes or stops. If j stops, it is
+// added to t and reported to w.
+func (t *JobTable) foreground(j *job, w io.Writer) error {
+	select {
+	case <-j.done:
+	case <-j.stop:
+	}
+	if j.term != nil && j.pgid != 0 {
+		// Take the terminal back.
+		if err := tcsetpgrp(j.term.fd, j.term.pgid); err != nil {
+			fmt.Fprintf(w, "taking back terminal: %v\n", err)
+		}
+	}
+	if !j.finished() && j.isStopped() {
+		if j.id == 0 {
+			t.add(j)
+		}
+		fmt.Fprintf(w, "\n%v\n", j)
+		return errStopped
+	}
+	t.remove(j)
+	return j.wait()
+}
+
+// continueForeground places the stopped or running job j in the foreground
+// and waits for it.
+func (t *JobTable) continueForeground(j *job, w io.Writer) error {
+	if j.term != nil && j.pgid != 0 {
+		if err := tcsetpgrp(j.term.fd, j.pgid); err != nil {
+			return err
+		}
+		if err := j.resume(); err != nil {
+			return err
+		}
+	}
+	return t.foreground(j, w)
+}
+
+// Report writes the finished jobs to w and removes them from t.
+func (t *JobTable) Report(w io.Writer) {
+	for _, j := range t.list() {
+		if j.finished() {
+			fmt.Fprintln(w, j)
+			t.remove(j)
+		}
+	}
+}
+
+// commandLine returns the command line of commands for display.
+func commandLine(commands []command) string {
+	lines := make([]string, len(commands))
+	for i, c := range commands {
+		lines[i] = strings.Join(c.args, " ")
+	}
+	return strings.Join(lines, " | ")
+}
+
+// startJob starts stmt in the background.
+func (e *Evaluator) startJob(stmt ast.Stmt) error {
+	var commands []command
+	switch x := stmt.(type) {
+	case *ast.ExecStmt:
+		args, redirs, err := e.evalExec(x)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			closers, err := applyRedirects(redirs, &stream{})
+			closeAll(closers)
+			return err
+		}
+		commands = []command{{args: args, redirs: redirs}}
+	case *ast.PipeStmt:
+		var err error
+		commands, err = e.evalPipe(x)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot run in background: %T", stmt)
+	}
+	// Background jobs are not canceled by interrupts, and do not read
+	// from the standard input of the shell.
+	p, err := e.makePipe(context.Background(), commands, nil)
+	if err != nil {
+		return err
+	}
+	j := e.jobs.newJob(commandLine(commands))
+	j.run(p, false)
+	e.jobs.add(j)
+	fmt.Fprintf(e.err, "[%d] %s\n", j.id, j.line)
+	return nil
+}
@@ -0,0 +1,90 @@
+package eval
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/elpinal/coco3/parser"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes by jobs.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestJobs(t *testing.T) {
+	tests := []struct {
+		src    string
+		out    string
+		status int
+	}{
+		{"echo a & wait", "a\n", 0},
+		{"echo a | tr a b & wait", "b\n", 0},
+		{"sh -c 'exit 3' & wait", "", 3},
+		{"sh -c 'exit 3' &; true; wait 1", "", 3},
+		{"sh -c 'exit 3' &; true; wait %1", "", 3},
+		{"sh -c 'exit 3' &\nfg", "sh -c exit 3\n", 3},
+		{"sleep 0.1 &; echo b; wait", "b\n", 0},
+		{"sleep 0.1 &; jobs; wait", "[1]  Running   sleep 0.1\n", 0},
+	}
+	for _, test := range tests {
+		e, _ := runTest(t, test.src, test.out, test.status)
+		if n := len(e.jobs.list()); n != 0 {
+			t.Errorf("Eval(%q): %d jobs remain", test.src, n)
+		}
+	}
+}
+
+func TestJobsReport(t *testing.T) {
+	f, err := parser.ParseSrc([]byte("sh -c 'exit 2' &; sleep 0.5 &; wait 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := NewJobTable()
+	e := New(nil, ioutil.Discard, ioutil.Discard, nil)
+	e.SetJobs(jobs)
+	if err := e.Eval(f.Lines); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	jobs.Report(&buf)
+	if got := buf.String(); got != "" {
+		t.Errorf("Report: got %q, want %q", got, "")
+	}
+
+	f, err = parser.ParseSrc([]byte("sh -c 'exit 2' &; sleep 0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Eval(f.Lines); err != nil {
+		t.Fatal(err)
+	}
+	jobs.Report(&buf)
+	if got, want := buf.String(), "[3]  Exit 2    sh -c exit 2\n"; got != want {
+		t.Errorf("Report: got %q, want %q", got, want)
+	}
+	if n := len(jobs.list()); n != 1 {
+		t.Errorf("got %d jobs, want 1", n)
+	}
+}
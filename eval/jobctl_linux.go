@@ -0,0 +1,104 @@
+package eval
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// EnableJobControl enables job control on the terminal fd, which must be the
+// controlling terminal of the shell with the shell in its foreground.
+// Pipes started afterwards get their own process groups, and those in the
+// foreground are given the terminal so that they can be stopped with Ctrl-Z.
+func (t *JobTable) EnableJobControl(fd int) error {
+	pgid, err := tcgetpgrp(fd)
+	if err != nil {
+		return err
+	}
+	if pgid != syscall.Getpgrp() {
+		return errors.New("the shell is not in the foreground of the terminal")
+	}
+	// The shell itself must not be stopped by the signals for job
+	// control. Handled signals, unlike ignored ones, are reset for
+	// child processes.
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.term = &terminal{fd: fd, pgid: pgid}
+	return nil
+}
+
+func tcgetpgrp(fd int) (int, error) {
+	var pgid int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgid)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(pgid), nil
+}
+
+// tcsetpgrp places the process group pgid in the foreground of the terminal
+// fd. SIGTTOU is blocked meanwhile so that the shell can take the terminal
+// back from the background.
+func tcsetpgrp(fd int, pgid int) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	set := uint64(1) << (uint(syscall.SIGTTOU) - 1)
+	var old uint64
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, sigBlock, uintptr(unsafe.Pointer(&set)), uintptr(unsafe.Pointer(&old)), 8, 0, 0); errno != 0 {
+		return errno
+	}
+	defer syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, sigSetmask, uintptr(unsafe.Pointer(&old)), 0, 8, 0, 0)
+	id := int32(pgid)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&id)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+const (
+	sigBlock   = 0
+	sigSetmask = 2
+
+	pPID = 1
+
+	wNOWAIT = 0x1000000
+
+	cldStopped   = 5
+	cldContinued = 6
+)
+
+// waitid waits for a change of the state of the process pid without reaping
+// it, and returns the si_code of the result.
+func waitid(pid int, options int) (int32, error) {
+	// siginfo_t is 128 bytes; si_code is the third int.
+	var info [128]byte
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(pid), uintptr(unsafe.Pointer(&info[0])), uintptr(options|wNOWAIT), 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return 0, errno
+		}
+		return *(*int32)(unsafe.Pointer(&info[8])), nil
+	}
+}
+
+// waitStop waits until the process pid stops or exits, and reports whether
+// it stopped.
+func waitStop(pid int) (bool, error) {
+	code, err := waitid(pid, syscall.WEXITED|syscall.WSTOPPED)
+	return code == cldStopped, err
+}
+
+// waitCont waits until the stopped process pid is continued or exits, and
+// reports whether it is continued.
+func waitCont(pid int) (bool, error) {
+	code, err := waitid(pid, syscall.WEXITED|syscall.WCONTINUED)
+	return code == cldContinued, err
+}
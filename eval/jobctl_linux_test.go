@@ -0,0 +1,190 @@
+package eval
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/elpinal/coco3/parser"
+)
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// openPTY opens a pseudo-terminal and returns its master and slave.
+func openPTY(t *testing.T) (*os.File, *os.File) {
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals are not available: %v", err)
+	}
+	var unlock int32
+	if err := ioctl(m.Fd(), syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		t.Fatal(err)
+	}
+	var n uint32
+	if err := ioctl(m.Fd(), syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals are not available: %v", err)
+	}
+	return m, s
+}
+
+// TestJobControl runs testJobControl in a new session whose controlling
+// terminal is a pseudo-terminal, and types Ctrl-Z while it waits for a
+// command in the foreground.
+func TestJobControl(t *testing.T) {
+	if os.Getenv("COCO3_TEST_JOB_CONTROL") == "1" {
+		testJobControl(t)
+		return
+	}
+	m, s := openPTY(t)
+	defer m.Close()
+	defer s.Close()
+	go ioutil.ReadAll(m) // discard echoes
+
+	// The child tells when to start and stop typing Ctrl-Z.
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestJobControl$")
+	cmd.Env = append(os.Environ(), "COCO3_TEST_JOB_CONTROL=1")
+	cmd.Stdin = s
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.ExtraFiles = []*os.File{pw}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	pw.Close()
+
+	typing := make(chan bool)
+	go func() {
+		b := make([]byte, 1)
+		for {
+			if _, err := pr.Read(b); err != nil {
+				return
+			}
+			typing <- b[0] == 'r'
+		}
+	}()
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	timeout := time.After(10 * time.Second)
+	var tick <-chan time.Time
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("%v\n%s", err, out.Bytes())
+			}
+			return
+		case b := <-typing:
+			tick = nil
+			if b {
+				tick = time.Tick(50 * time.Millisecond)
+			}
+		case <-tick:
+			m.Write([]byte{0x1a}) // Ctrl-Z
+		case <-timeout:
+			cmd.Process.Kill()
+			t.Fatalf("timeout\n%s", out.Bytes())
+		}
+	}
+}
+
+func testJobControl(t *testing.T) {
+	jobs := NewJobTable()
+	if err := jobs.EnableJobControl(0); err != nil {
+		t.Fatal(err)
+	}
+	var stdout syncBuffer
+	e := New(nil, &stdout, ioutil.Discard, nil)
+	e.SetJobs(jobs)
+	eval := func(src string) {
+		f, err := parser.ParseSrc([]byte(src))
+		if err != nil {
+			t.Fatalf("parsing %q: %v", src, err)
+		}
+		if err := e.Eval(f.Lines); err != nil {
+			t.Fatalf("Eval(%q): %v", src, err)
+		}
+	}
+	checkForeground := func() {
+		pgid, err := tcgetpgrp(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pgid != syscall.Getpgrp() {
+			t.Errorf("the shell is not in the foreground")
+		}
+	}
+
+	ctl := os.NewFile(3, "ctl")
+	ctl.Write([]byte{'r'}) // start typing Ctrl-Z
+	eval("sleep 5")
+	ctl.Write([]byte{'s'})
+	if got, want := e.Status(), 128+int(syscall.SIGTSTP); got != want {
+		t.Fatalf("status: got %d, want %d", got, want)
+	}
+	checkForeground()
+	j, err := jobs.lookup("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := j.String(), "[1]  Stopped   sleep 5"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := syscall.Kill(-j.pgid, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	eval("wait")
+	if got, want := e.Status(), 128+int(syscall.SIGKILL); got != want {
+		t.Errorf("status: got %d, want %d", got, want)
+	}
+
+	eval("sh -c 'kill -STOP $$; exit 3' | cat")
+	if got, want := e.Status(), 128+int(syscall.SIGTSTP); got != want {
+		t.Fatalf("status: got %d, want %d", got, want)
+	}
+	checkForeground()
+	stdout.Reset()
+	eval("jobs")
+	if got, want := stdout.String(), "[1]  Stopped   sh -c kill -STOP $$; exit 3 | cat\n"; got != want {
+		t.Errorf("jobs: got %q, want %q", got, want)
+	}
+	eval("bg; wait")
+	if got := e.Status(); got != 0 {
+		t.Errorf("status: got %d, want 0", got)
+	}
+
+	eval("sh -c 'kill -STOP $$; exit 3'")
+	eval("fg")
+	if got := e.Status(); got != 3 {
+		t.Errorf("status: got %d, want 3", got)
+	}
+	checkForeground()
+	if n := len(jobs.list()); n != 0 {
+		t.Errorf("%d jobs remain", n)
+	}
+}
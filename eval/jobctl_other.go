@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package eval
+
+// EnableJobControl enables job control on the terminal fd.
+// Job control is supported only on Linux; elsewhere it does nothing.
+func (t *JobTable) EnableJobControl(fd int) error {
+	return nil
+}
+
+func tcsetpgrp(fd int, pgid int) error {
+	return errNoJobControl
+}
+
+func waitStop(pid int) (bool, error) {
+	return false, errNoJobControl
+}
+
+func waitCont(pid int) (bool, error) {
+	return false, errNoJobControl
+}
@@ -0,0 +1,25 @@
+package eval
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// stoppedStatus is the exit status of a stopped job.
+const stoppedStatus = 128 + int(syscall.SIGTSTP)
+
+// setProcessGroup makes cmd start in the process group pgid, or in a new one
+// if pgid is 0. If fg is true, the group is placed in the foreground of the
+// terminal tty.
+func setProcessGroup(cmd *exec.Cmd, pgid int, fg bool, tty int) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: pgid}
+	if fg {
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = tty
+	}
+}
+
+// continueGroup continues the stopped processes in the process group pgid.
+func continueGroup(pgid int) error {
+	return syscall.Kill(-pgid, syscall.SIGCONT)
+}
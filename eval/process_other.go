@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package eval
+
+import "os/exec"
+
+// stoppedStatus is the exit status of a stopped job. Jobs are never stopped
+// without job control; the value is the one on Linux.
+const stoppedStatus = 128 + 20
+
+// setProcessGroup does nothing, as job control is supported only on Linux.
+func setProcessGroup(cmd *exec.Cmd, pgid int, fg bool, tty int) {}
+
+func continueGroup(pgid int) error {
+	return errNoJobControl
+}
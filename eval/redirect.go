@@ -0,0 +1,107 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/elpinal/coco3/ast"
+	"github.com/elpinal/coco3/token"
+)
+
+// A redirect represents a redirection of a command.
+type redirect struct {
+	pos  token.Pos
+	op   token.Token
+	file string // file name for the redirections from or to files
+	text string // input for token.HEREDOC and token.HERESTR
+}
+
+func (e *Evaluator) evalRedirect(x *ast.UnaryExpr) (redirect, error) {
+	r := redirect{pos: x.Pos(), op: x.Op}
+	switch x.Op {
+	case token.ERRTOOUT:
+		return r, nil
+	case token.HEREDOC:
+		// The body of a here-document is taken literally.
+		r.text = x.X.(*ast.HereDoc).Body
+		return r, nil
+	}
+	s, err := e.evalExpr(x.X)
+	if err != nil {
+		return redirect{}, locate(x.Pos(), err)
+	}
+	if x.Op == token.HERESTR {
+		r.text = strings.Join(s, " ") + "\n"
+		return r, nil
+	}
+	if len(s) == 0 {
+		return redirect{}, locate(x.Pos(), fmt.Errorf("cannot redirect"))
+	}
+	if len(s) > 1 {
+		return redirect{}, locate(x.Pos(), fmt.Errorf("cannot redirect to multi-word filename"))
+	}
+	r.file = s[0]
+	return r, nil
+}
+
+// apply opens the file of r and replaces the corresponding stream of s with
+// it. The opened file, if any, is returned to be closed by the caller.
+func (r redirect) apply(s *stream) (io.Closer, error) {
+	switch r.op {
+	case token.ERRTOOUT:
+		s.err = s.out
+		return nil, nil
+	case token.HEREDOC, token.HERESTR:
+		s.in = strings.NewReader(r.text)
+		return nil, nil
+	case token.REDIRIN:
+		f, err := os.Open(r.file)
+		if err != nil {
+			return nil, err
+		}
+		s.in = f
+		return f, nil
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if r.op == token.APPENDOUT || r.op == token.APPENDERR {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(r.file, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	switch r.op {
+	case token.REDIROUT, token.APPENDOUT:
+		s.out = f
+	case token.REDIRERR, token.APPENDERR:
+		s.err = f
+	case token.REDIRALL:
+		s.out = f
+		s.err = f
+	}
+	return f, nil
+}
+
+// applyRedirects applies redirs to s in order, and returns the opened files.
+func applyRedirects(redirs []redirect, s *stream) ([]io.Closer, error) {
+	var closers []io.Closer
+	for _, r := range redirs {
+		c, err := r.apply(s)
+		if err != nil {
+			closeAll(closers)
+			return nil, locate(r.pos, err)
+		}
+		if c != nil {
+			closers = append(closers, c)
+		}
+	}
+	return closers, nil
+}
+
+func closeAll(closers []io.Closer) {
+	for _, c := range closers {
+		c.Close()
+	}
+}
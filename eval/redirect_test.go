@@ -0,0 +1,165 @@
+package eval
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elpinal/coco3/parser"
+)
+
+func TestRedirect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coco3-redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("D", dir)
+	defer os.Unsetenv("D")
+
+	tests := []struct {
+		src   string
+		out   string
+		errs  string
+		files map[string]string
+	}{
+		{
+			src:   "echo a > $D/f",
+			files: map[string]string{"f": "a\n"},
+		},
+		{
+			src:   "echo a > $D/f; echo b >> $D/f",
+			files: map[string]string{"f": "a\nb\n"},
+		},
+		{
+			src:   "echo a > $D/f; echo b > $D/f",
+			files: map[string]string{"f": "b\n"},
+		},
+		{
+			src:   "sh -c 'echo o; echo e >&2' 2> $D/e",
+			out:   "o\n",
+			files: map[string]string{"e": "e\n"},
+		},
+		{
+			src:   "sh -c 'echo e >&2' 2> $D/e; sh -c 'echo f >&2' 2>> $D/e",
+			files: map[string]string{"e": "e\nf\n"},
+		},
+		{
+			src: "sh -c 'echo o; echo e >&2' 2>&1",
+			out: "o\ne\n",
+		},
+		{
+			src:   "sh -c 'echo o; echo e >&2' > $D/f 2>&1",
+			files: map[string]string{"f": "o\ne\n"},
+		},
+		{
+			src:   "sh -c 'echo o; echo e >&2' 2>&1 > $D/f",
+			out:   "e\n",
+			files: map[string]string{"f": "o\n"},
+		},
+		{
+			src:   "sh -c 'echo o; echo e >&2' &> $D/f",
+			files: map[string]string{"f": "o\ne\n"},
+		},
+		{
+			src: "echo a > $D/f; cat < $D/f",
+			out: "a\n",
+		},
+		{
+			src:   "echo a > '" + filepath.Join(dir, "quoted name") + "'",
+			files: map[string]string{"quoted name": "a\n"},
+		},
+		{
+			src:   "> $D/empty",
+			files: map[string]string{"empty": ""},
+		},
+		{
+			// Redirections do not affect the following commands.
+			src: "echo a > $D/f; echo b",
+			out: "b\n",
+		},
+		{
+			src: "sh -c 'echo o; echo e >&2' 2>&1 | tr a-z A-Z",
+			out: "O\nE\n",
+		},
+		{
+			src:   "sh -c 'echo o; echo e >&2' 2> $D/e | tr a-z A-Z > $D/f",
+			files: map[string]string{"e": "e\n", "f": "O\n"},
+		},
+		{
+			src: "echo abc > $D/f; < $D/f tr a-z A-Z | tr B b",
+			out: "AbC\n",
+		},
+		{
+			src:   "echo a | sh -c 'cat; echo e >&2' 2>> $D/e2 | cat; echo b | cat 2>> $D/e2",
+			out:   "a\nb\n",
+			files: map[string]string{"e2": "e\n"},
+		},
+	}
+	for _, test := range tests {
+		_, errs := runTest(t, test.src, test.out, 0)
+		if errs != test.errs {
+			t.Errorf("Eval(%q): error output: got %q, want %q", test.src, errs, test.errs)
+		}
+		for name, want := range test.files {
+			b, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Errorf("Eval(%q): %v", test.src, err)
+				continue
+			}
+			if got := string(b); got != want {
+				t.Errorf("Eval(%q): file %s: got %q, want %q", test.src, name, got, want)
+			}
+		}
+	}
+}
+
+func TestRedirectError(t *testing.T) {
+	f, err := parser.ParseSrc([]byte("cat < /nonexistent/file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(nil, ioutil.Discard, ioutil.Discard, nil)
+	if err := e.Eval(f.Lines); err == nil {
+		t.Error("Eval: should fail")
+	}
+	if got, want := e.Status(), 1; got != want {
+		t.Errorf("status: got %d, want %d", got, want)
+	}
+}
+
+func TestPipeEarlyExit(t *testing.T) {
+	f, err := parser.ParseSrc([]byte("yes | head -n 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	e := New(nil, &out, ioutil.Discard, nil)
+	if err := e.Eval(f.Lines); err != nil {
+		t.Errorf("Eval: %v", err)
+	}
+	if got, want := out.String(), "y\n"; got != want {
+		t.Errorf("output: got %q, want %q", got, want)
+	}
+}
+
+func TestHereDoc(t *testing.T) {
+	tests := []struct {
+		src string
+		out string
+	}{
+		{"cat <<EOF\na\n  b $HOME\nEOF\necho c", "a\n  b $HOME\nc\n"},
+		{"cat << 'END'\nEOF\nEND", "EOF\n"},
+		{"cat <<EOF | tr a-z A-Z\nabc\nEOF", "ABC\n"},
+		{"cat <<A; cat <<B\na\nA\nb\nB\necho c", "a\nb\nc\n"},
+		{"cat <<EOF\nEOF", ""},
+		{"tr a-z A-Z <<<'hello world'", "HELLO WORLD\n"},
+		{"tr a-z A-Z <<< abc", "ABC\n"},
+		{"cat <<<$(echo a b)", "a b\n"},
+	}
+	for _, test := range tests {
+		runTest(t, test.src, test.out, 0)
+	}
+}
@@ -0,0 +1,103 @@
+package eval
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Vars is a store of shell variables. A variable holds a list of words; a
+// scalar is a list of one word.
+//
+// Unlike environment variables, shell variables are not passed to commands
+// unless exported. It is safe for concurrent use.
+type Vars struct {
+	mu       sync.Mutex
+	m        map[string][]string
+	exported map[string]bool
+}
+
+func NewVars() *Vars {
+	return &Vars{
+		m:        make(map[string][]string),
+		exported: make(map[string]bool),
+	}
+}
+
+// Get returns the value of the variable named name, looking up the
+// environment if it is not a shell variable.
+func (v *Vars) Get(name string) ([]string, bool) {
+	if x, ok := v.lookup(name); ok {
+		return x, true
+	}
+	if s, ok := os.LookupEnv(name); ok {
+		return []string{s}, true
+	}
+	return nil, false
+}
+
+// lookup returns the value of the shell variable named name.
+func (v *Vars) lookup(name string) ([]string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	x, ok := v.m[name]
+	return x, ok
+}
+
+// lookupEnv returns the value of the variable named name in env, a list of
+// "key=value" strings. The last one wins if there are duplicates.
+func lookupEnv(env []string, name string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], name+"=") {
+			return env[i][len(name)+1:], true
+		}
+	}
+	return "", false
+}
+
+// Set sets the value of the variable named name. If the variable is
+// exported or inherited from the environment, the environment is updated as
+// well.
+func (v *Vars) Set(name string, value []string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.m[name] = value
+	if _, ok := os.LookupEnv(name); ok || v.exported[name] {
+		return os.Setenv(name, strings.Join(value, " "))
+	}
+	return nil
+}
+
+// Export marks the variable named name as exported, and copies its value to
+// the environment.
+func (v *Vars) Export(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.exported[name] = true
+	if x, ok := v.m[name]; ok {
+		return os.Setenv(name, strings.Join(x, " "))
+	}
+	return nil
+}
+
+// Unset removes the variable named name, from the environment as well.
+func (v *Vars) Unset(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.m, name)
+	delete(v.exported, name)
+	return os.Unsetenv(name)
+}
+
+// exportedNames returns the names of the exported variables in sorted order.
+func (v *Vars) exportedNames() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	names := make([]string, 0, len(v.exported))
+	for name := range v.exported {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
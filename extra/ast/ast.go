@@ -1,29 +1,52 @@
 package ast
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/elpinal/coco3/extra/token"
 	"github.com/elpinal/coco3/extra/types"
 )
 
+// A Stmt is a command, a pipe or a definition.
+type Stmt interface {
+	Stmt()
+}
+
+func (_ *Command) Stmt() {}
+func (_ *Pipe) Stmt()    {}
+func (_ *Def) Stmt()     {}
+
 type Command struct {
 	Name token.Token
 	Args []Expr
 }
 
+// A Pipe is two or more commands, each of which reads the output of the
+// previous one.
+type Pipe struct {
+	Commands []*Command
+}
+
 // Expressions
 
 type Expr interface {
 	Expr()
+	// Type returns the type of the expression, or nil if it depends on
+	// the environment.
 	Type() types.Type
 }
 
 func (_ *String) Expr() {}
 func (_ *Int) Expr()    {}
 func (_ *Ident) Expr()  {}
+func (_ *Bool) Expr()   {}
+func (_ *Path) Expr()   {}
 func (_ *Empty) Expr()  {}
 func (_ *Cons) Expr()   {}
+func (_ *Record) Expr() {}
+func (_ *App) Expr()    {}
+func (_ *Fn) Expr()     {}
 
 // Simple types
 
@@ -39,6 +62,16 @@ type (
 	Ident struct {
 		Lit string
 	}
+
+	Bool struct {
+		Value bool
+	}
+
+	// A Path is the name of an existing file, which is given as a string
+	// literal.
+	Path struct {
+		Lit string
+	}
 )
 
 func (_ *String) Type() types.Type {
@@ -53,6 +86,14 @@ func (_ *Ident) Type() types.Type {
 	return types.Ident
 }
 
+func (_ *Bool) Type() types.Type {
+	return types.Bool
+}
+
+func (_ *Path) Type() types.Type {
+	return types.Path
+}
+
 func (s *String) String() string {
 	return fmt.Sprintf("%q", s.Lit)
 }
@@ -65,6 +106,14 @@ func (id *Ident) String() string {
 	return fmt.Sprintf("%q", id.Lit)
 }
 
+func (b *Bool) String() string {
+	return fmt.Sprint(b.Value)
+}
+
+func (p *Path) String() string {
+	return fmt.Sprintf("%q", p.Lit)
+}
+
 // Lists
 
 type List interface {
@@ -74,19 +123,49 @@ type List interface {
 
 type (
 	Cons struct {
-		Head string
+		Head Expr
 		Tail List
 	}
 
 	Empty struct{}
 )
 
+// Type returns List a, as the empty list can be a list of any type.
 func (e *Empty) Type() types.Type {
-	return types.StringList
+	return types.List{Elem: types.Var{Name: "a"}}
 }
 
+// Type returns the type of the list by its head; the tail is assumed to
+// have the same type.
 func (c *Cons) Type() types.Type {
-	return types.StringList
+	t := c.Head.Type()
+	if t == nil {
+		return nil
+	}
+	return types.List{Elem: t}
+}
+
+func (e *Empty) String() string {
+	return "[]"
+}
+
+func (c *Cons) String() string {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	var l List = c
+	for {
+		x, ok := l.(*Cons)
+		if !ok {
+			break
+		}
+		if x != c {
+			buf.WriteString(", ")
+		}
+		fmt.Fprint(&buf, x.Head)
+		l = x.Tail
+	}
+	buf.WriteByte(']')
+	return buf.String()
 }
 
 func (e *Empty) Length() int {
@@ -97,6 +176,90 @@ func (c *Cons) Length() int {
 	return 1 + c.Tail.Length()
 }
 
+// Records
+
+// A Record gives the arguments of a command by name, e.g.
+// {count = 3, verbose = true}.
+type Record struct {
+	Fields []RecordField
+}
+
+type RecordField struct {
+	Name  token.Token
+	Value Expr
+}
+
+func (r *Record) Type() types.Type {
+	fs := make([]types.Field, len(r.Fields))
+	for i, f := range r.Fields {
+		t := f.Value.Type()
+		if t == nil {
+			return nil
+		}
+		fs[i] = types.Field{Name: f.Name.Lit, Type: t}
+	}
+	return types.Record{Fields: fs}
+}
+
+// Get returns the value of the field named name.
+func (r *Record) Get(name string) (Expr, bool) {
+	for _, f := range r.Fields {
+		if f.Name.Lit == name {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Record) String() string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range r.Fields {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%s = %v", f.Name.Lit, f.Value)
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// Functions
+
+type (
+	// An App applies the command or the function named Func to Args,
+	// which may be fewer than its parameters, e.g. (git status).
+	App struct {
+		Func token.Token
+		Args []Expr
+	}
+
+	// A Fn is a function which binds Param to its argument and returns
+	// the value of Body, e.g. fn (x : String) -> f x.
+	Fn struct {
+		Param     token.Token
+		ParamType types.Type
+		Body      Expr
+	}
+)
+
+func (_ *App) Type() types.Type {
+	return nil
+}
+
+func (_ *Fn) Type() types.Type {
+	return nil
+}
+
+func (a *App) String() string {
+	return fmt.Sprintf("(%s %v)", a.Func.Lit, a.Args)
+}
+
+func (f *Fn) String() string {
+	return fmt.Sprintf("(fn (%s : %v) -> %v)", f.Param.Lit, f.ParamType, f.Body)
+}
+
+// A Def binds Name to the value of Expr, e.g. def x = 'a'.
 type Def struct {
 	Name token.Token
 	Expr Expr
@@ -10,8 +10,11 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
@@ -29,16 +32,25 @@ import (
 
 type Env struct {
 	cmds map[string]typed.Command
+
+	// vars holds the values bound by definitions.
+	vars map[string]ast.Expr
+
 	Option
 }
 
 type Option struct {
 	DB *sqlx.DB
+
+	// IO holds the streams of commands. Nil streams are replaced with
+	// os.Stdin, os.Stdout and os.Stderr.
+	IO typed.IO
 }
 
 func New(opt Option) Env {
 	return Env{
 		Option: opt,
+		vars:   make(map[string]ast.Expr),
 		cmds: map[string]typed.Command{
 			"exec":     execCommand,
 			"execenv":  execenvCommand,  // exec with env
@@ -51,6 +63,7 @@ func New(opt Option) Env {
 			"history":  historyCommand,
 
 			"remove": removeCommand,
+			"mkdir":  mkdirCommand,
 
 			"cat":  catCommand,
 			"ls":   lsCommand,
@@ -81,61 +94,298 @@ func New(opt Option) Env {
 }
 
 func WithoutDefault() Env {
-	return Env{cmds: make(map[string]typed.Command)}
+	return Env{
+		cmds: make(map[string]typed.Command),
+		vars: make(map[string]ast.Expr),
+	}
 }
 
 func (e *Env) Bind(name string, c typed.Command) {
 	e.cmds[name] = c
 }
 
-func (e *Env) Eval(command *ast.Command) (err error) {
+// Eval evaluates stmt. A definition binds its name in e, and the following
+// commands can refer to the value by the name.
+func (e *Env) Eval(stmt ast.Stmt) error {
+	switch x := stmt.(type) {
+	case *ast.Command:
+		return e.evalCommand(x)
+	case *ast.Pipe:
+		return e.evalPipe(x)
+	case *ast.Def:
+		return e.define(x)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("unexpected statement type: %T", stmt)
+}
+
+// define binds the name of def to the value of its expression.
+func (e *Env) define(def *ast.Def) error {
+	x, _, err := e.newChecker().check(def.Expr, nil, nil)
+	if err == nil {
+		var v ast.Expr
+		v, err = e.eval(x, nil)
+		if err == nil {
+			e.vars[def.Name.Lit] = v
+			return nil
+		}
+	}
+	return &parser.ParseError{
+		Msg:    err.Error(),
+		Line:   def.Name.Line,
+		Column: def.Name.Column,
+	}
+}
+
+// Lookup returns the value bound to name by a definition.
+func (e *Env) Lookup(name string) (ast.Expr, bool) {
+	v, ok := e.vars[name]
+	return v, ok
+}
+
+// streams returns the streams of commands.
+func (e *Env) streams() typed.IO {
+	s := e.IO
+	if s.In == nil {
+		s.In = os.Stdin
+	}
+	if s.Out == nil {
+		s.Out = os.Stdout
+	}
+	if s.Err == nil {
+		s.Err = os.Stderr
+	}
+	return s
+}
+
+// evalCommand runs the action of command.
+func (e *Env) evalCommand(command *ast.Command) error {
 	if command == nil {
 		return nil
 	}
-	tc, found := e.cmds[command.Name.Lit]
-	if !found {
-		return &parser.ParseError{
-			Msg:    fmt.Sprintf("no such typed command: %q", command.Name.Lit),
-			Line:   command.Name.Line,
-			Column: command.Name.Column,
+	a, err := e.action(command)
+	if err != nil {
+		return err
+	}
+	defer notifyInterrupt()()
+	return runAction(a, e.streams())
+}
+
+// evalPipe runs the actions of the commands of p concurrently. The commands
+// are checked before any of them runs.
+func (e *Env) evalPipe(p *ast.Pipe) error {
+	actions := make([]*typed.Action, len(p.Commands))
+	for i, command := range p.Commands {
+		a, err := e.action(command)
+		if err != nil {
+			return err
 		}
+		actions[i] = a
 	}
-	if len(command.Args) != len(tc.Params) {
+	defer notifyInterrupt()()
+	return runPipe(actions, e.streams())
+}
+
+// action applies the command, the function or the action named by the name
+// of command to the arguments, and returns the resulting action.
+func (e *Env) action(command *ast.Command) (*typed.Action, error) {
+	errorf := func(format string, args ...interface{}) error {
 		return &parser.ParseError{
-			Msg:    fmt.Sprintf("the length of args (%d) != the one of params (%d)", len(command.Args), len(tc.Params)),
+			Msg:    fmt.Sprintf(format, args...),
 			Line:   command.Name.Line,
 			Column: command.Name.Column,
 		}
 	}
+	v, found := e.lookup(command.Name.Lit)
+	if !found {
+		return nil, errorf("no such typed command: %q", command.Name.Lit)
+	}
+	c := e.newChecker()
+	params, result := split(c.instantiate(v.Type()))
+	if result != types.Command {
+		return nil, errorf("not a command: %s has type %v", command.Name.Lit, v.Type())
+	}
+	if len(command.Args) != len(params) {
+		return nil, errorf("the length of args (%d) != the one of params (%d)", len(command.Args), len(params))
+	}
+	xs := make([]ast.Expr, len(command.Args))
 	for i, arg := range command.Args {
-		if arg.Type() != tc.Params[i] {
-			return &parser.ParseError{
-				Msg:    fmt.Sprintf("type mismatch: (%v) (type of %v) does not match with (%v) (expected type)", arg.Type(), arg, tc.Params[i]),
-				Line:   command.Name.Line,
-				Column: command.Name.Column,
-			}
+		x, t, err := c.check(arg, params[i], nil)
+		if err == nil {
+			err = c.unify(arg, t, params[i])
+		}
+		if err != nil {
+			return nil, errorf("%v", err)
 		}
+		xs[i] = x
 	}
+	for _, x := range xs {
+		a, err := e.eval(x, nil)
+		if err != nil {
+			return nil, errorf("%v", err)
+		}
+		v, err = v.(*typed.Func).Apply(a)
+		if err != nil {
+			return nil, errorf("%v", err)
+		}
+	}
+	return v.(*typed.Action), nil
+}
+
+// notifyInterrupt keeps interrupts from terminating the process, so that
+// they only reach the commands running, until the returned function is
+// called.
+func notifyInterrupt() (stop func()) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	return func() {
+		signal.Stop(sig)
+		close(sig)
+	}
+}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	defer close(c)
-	defer signal.Stop(c)
-
+// runAction runs a with the streams s.
+func runAction(a *typed.Action, s typed.IO) (err error) {
 	defer func() {
 		r := recover()
 		if r == nil {
 			return
 		}
 		var ok bool
-		// may overwrite error of tc.Fn.
+		// may overwrite error of the command.
 		err, ok = r.(error)
 		if !ok {
 			panic(r)
 		}
 	}()
+	return a.Run(s)
+}
+
+// runPipe runs the actions concurrently, connecting the output of each to the
+// input of the next. It reports the errors of the actions except the last
+// one to s.Err, and returns the error of the last one.
+func runPipe(actions []*typed.Action, s typed.IO) error {
+	n := len(actions)
+	streams := make([]typed.IO, n)
+	// ends holds the ends of the pipes used by each action.
+	ends := make([][]io.Closer, n)
+	shared := guard(s)
+	for i := range streams {
+		streams[i] = shared
+	}
+	for i := 0; i < n-1; i++ {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			for _, cs := range ends {
+				closeAll(cs)
+			}
+			return err
+		}
+		streams[i].Out = pw
+		streams[i+1].In = pr
+		ends[i] = append(ends[i], pw)
+		ends[i+1] = append(ends[i+1], pr)
+	}
+
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i, a := range actions {
+		go func(i int, a *typed.Action) {
+			defer wg.Done()
+			errs[i] = runAction(a, streams[i])
+			// Close the ends so that the other end sees EOF or EPIPE.
+			closeAll(ends[i])
+		}(i, a)
+	}
+	wg.Wait()
+
+	for _, err := range errs[:n-1] {
+		if err == nil || ignorable(err) {
+			continue
+		}
+		fmt.Fprintf(s.Err, "%v\n", err)
+	}
+	return errs[n-1]
+}
+
+// guard returns s whose Err, and Out if it is the same writer, can be
+// written to concurrently by the actions of a pipe. Files need no guard.
+func guard(s typed.IO) typed.IO {
+	if s.Err == nil {
+		return s
+	}
+	if _, ok := s.Err.(*os.File); ok {
+		return s
+	}
+	w := &lockedWriter{w: s.Err}
+	if sameWriter(s.Out, s.Err) {
+		s.Out = w
+	}
+	s.Err = w
+	return s
+}
 
-	return tc.Fn(command.Args, e.DB)
+func sameWriter(a, b io.Writer) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
+// A lockedWriter serializes writes to w.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+func closeAll(cs []io.Closer) {
+	for _, c := range cs {
+		c.Close()
+	}
+}
+
+// ignorable reports whether err, returned by a command in a pipe, need not
+// be reported: it only tells the exit status of the command, or that the
+// next command exited without reading all the output.
+func ignorable(err error) bool {
+	switch x := errors.Cause(err).(type) {
+	case *exec.ExitError:
+		return true
+	case *os.PathError:
+		return x.Err == syscall.EPIPE
+	}
+	return false
+}
+
+// ExitStatus returns the exit status for err returned by Eval: that of the
+// external command which failed, 127 if it is not found, or 1 for the other
+// errors, including parse and type errors.
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	switch x := errors.Cause(err).(type) {
+	case *exec.ExitError:
+		status := x.Sys().(syscall.WaitStatus)
+		if status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return status.ExitStatus()
+	case *exec.Error:
+		if x.Err == exec.ErrNotFound {
+			return 127
+		}
+	}
+	return 1
 }
 
 func toSlice(list ast.List) ([]string, error) {
@@ -143,7 +393,14 @@ func toSlice(list ast.List) ([]string, error) {
 	for {
 		switch x := list.(type) {
 		case *ast.Cons:
-			ret = append(ret, x.Head)
+			switch h := x.Head.(type) {
+			case *ast.String:
+				ret = append(ret, h.Lit)
+			case *ast.Path:
+				ret = append(ret, h.Lit)
+			default:
+				return nil, fmt.Errorf("unexpected element type: %T", h)
+			}
 			list = x.Tail
 		case *ast.Empty:
 			return ret, nil
@@ -155,24 +412,24 @@ func toSlice(list ast.List) ([]string, error) {
 
 var execCommand = typed.Command{
 	Params: []types.Type{types.String, types.StringList},
-	Fn: func(args []ast.Expr, _ *sqlx.DB) error {
+	Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		cmdArgs, err := toSlice(args[1].(ast.List))
 		if err != nil {
 			return errors.Wrap(err, "exec")
 		}
-		cmd := stdCmd(args[0].(*ast.String).Lit, cmdArgs...)
+		cmd := stdCmd(s, args[0].(*ast.String).Lit, cmdArgs...)
 		return cmd.Run()
 	},
 }
 
 var execenvCommand = typed.Command{
 	Params: []types.Type{types.StringList, types.String, types.StringList},
-	Fn: func(args []ast.Expr, _ *sqlx.DB) error {
+	Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		cmdArgs, err := toSlice(args[2].(ast.List))
 		if err != nil {
 			return errors.Wrap(err, "execenv")
 		}
-		cmd := stdCmd(args[1].(*ast.String).Lit, cmdArgs...)
+		cmd := stdCmd(s, args[1].(*ast.String).Lit, cmdArgs...)
 		env, err := toSlice(args[0].(ast.List))
 		if err != nil {
 			return errors.Wrap(err, "execenv")
@@ -189,13 +446,13 @@ var execenvCommand = typed.Command{
 
 var withpathCommand = typed.Command{
 	Params: []types.Type{types.StringList, types.String, types.StringList},
-	Fn: func(args []ast.Expr, _ *sqlx.DB) error {
+	Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		cmdArgs, err := toSlice(args[2].(ast.List))
 		if err != nil {
 			return errors.Wrap(err, "withpath")
 		}
 		// The command is searched for in the original PATH, as opposed to the extended PATH.
-		cmd := stdCmd(args[1].(*ast.String).Lit, cmdArgs...)
+		cmd := stdCmd(s, args[1].(*ast.String).Lit, cmdArgs...)
 		paths, err := toSlice(args[0].(ast.List))
 		if err != nil {
 			return errors.Wrap(err, "withpath")
@@ -211,19 +468,14 @@ var withpathCommand = typed.Command{
 }
 
 var repeatCommand = typed.Command{
-	Params: []types.Type{types.Int, types.String, types.StringList},
-	Fn: func(args []ast.Expr, _ *sqlx.DB) error {
+	Params: []types.Type{types.Int, types.Command},
+	Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		n, err := strconv.Atoi(args[0].(*ast.Int).Lit)
 		if err != nil {
 			return errors.Wrap(err, "repeat")
 		}
-		cmdArgs, err := toSlice(args[2].(ast.List))
-		if err != nil {
-			return errors.Wrap(err, "repeat")
-		}
 		for i := 0; i < n; i++ {
-			cmd := stdCmd(args[1].(*ast.String).Lit, cmdArgs...)
-			if err := cmd.Run(); err != nil {
+			if err := args[1].(*typed.Action).Run(s); err != nil {
 				return err
 			}
 		}
@@ -232,34 +484,29 @@ var repeatCommand = typed.Command{
 }
 
 var timeCommand = typed.Command{
-	Params: []types.Type{types.String, types.StringList},
-	Fn: func(args []ast.Expr, _ *sqlx.DB) error {
-		cmdArgs, err := toSlice(args[1].(ast.List))
-		if err != nil {
-			return errors.Wrap(err, "time")
-		}
-		cmd := stdCmd(args[0].(*ast.String).Lit, cmdArgs...)
+	Params: []types.Type{types.Command},
+	Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		start := time.Now()
-		if err := cmd.Run(); err != nil {
+		if err := args[0].(*typed.Action).Run(s); err != nil {
 			return err
 		}
 		end := time.Now()
 		elapsed := end.Sub(start)
-		fmt.Fprintf(os.Stdout, "elapsed time: %v\n", elapsed)
+		fmt.Fprintf(s.Out, "elapsed time: %v\n", elapsed)
 		return nil
 	},
 }
 
 var cdCommand = typed.Command{
-	Params: []types.Type{types.String},
-	Fn: func(args []ast.Expr, _ *sqlx.DB) error {
-		return os.Chdir(args[0].(*ast.String).Lit)
+	Params: []types.Type{types.Path},
+	Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
+		return os.Chdir(args[0].(*ast.Path).Lit)
 	},
 }
 
 var exitCommand = typed.Command{
 	Params: []types.Type{types.Int},
-	Fn: func(args []ast.Expr, _ *sqlx.DB) error {
+	Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		n, err := strconv.Atoi(args[0].(*ast.Int).Lit)
 		if err != nil {
 			return err
@@ -271,32 +518,32 @@ var exitCommand = typed.Command{
 
 var freeCommand = typed.Command{
 	Params: []types.Type{types.String, types.StringList},
-	Fn: func(args []ast.Expr, _ *sqlx.DB) error {
+	Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		cmdArgs, err := toSlice(args[1].(ast.List))
 		if err != nil {
 			return errors.Wrap(err, "free")
 		}
 		name := args[0].(*ast.String).Lit
 		cmd := exec.Cmd{Path: name, Args: append([]string{name}, cmdArgs...)}
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
+		cmd.Stdout = s.Out
+		cmd.Stderr = s.Err
+		cmd.Stdin = s.In
 		return cmd.Run()
 	},
 }
 
-func commandArgs(name string) func([]ast.Expr, *sqlx.DB) error {
-	return func(args []ast.Expr, _ *sqlx.DB) error {
+func commandArgs(name string) func([]ast.Expr, typed.IO, *sqlx.DB) error {
+	return func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		list, err := toSlice(args[0].(ast.List))
 		if err != nil {
 			return errors.Wrap(err, name)
 		}
-		return stdCmd(name, list...).Run()
+		return stdCmd(s, name, list...).Run()
 	}
 }
 
-func commandsInCommand(name string) func([]ast.Expr, *sqlx.DB) error {
-	return func(args []ast.Expr, _ *sqlx.DB) error {
+func commandsInCommand(name string) func([]ast.Expr, typed.IO, *sqlx.DB) error {
+	return func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		cmdArgs, err := toSlice(args[1].(ast.List))
 		if err != nil {
 			return errors.Wrap(err, name)
@@ -304,16 +551,16 @@ func commandsInCommand(name string) func([]ast.Expr, *sqlx.DB) error {
 		var cmd *exec.Cmd
 		switch lit := args[0].(*ast.Ident).Lit; lit {
 		case "command":
-			cmd = stdCmd(name, cmdArgs...)
+			cmd = stdCmd(s, name, cmdArgs...)
 		default:
-			cmd = stdCmd(name, append([]string{lit}, cmdArgs...)...)
+			cmd = stdCmd(s, name, append([]string{lit}, cmdArgs...)...)
 		}
 		return cmd.Run()
 	}
 }
 
-func goCommand1() func([]ast.Expr, *sqlx.DB) error {
-	return func(args []ast.Expr, _ *sqlx.DB) error {
+func goCommand1() func([]ast.Expr, typed.IO, *sqlx.DB) error {
+	return func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		name := "go"
 		cmdArgs, err := toSlice(args[1].(ast.List))
 		if err != nil {
@@ -322,22 +569,22 @@ func goCommand1() func([]ast.Expr, *sqlx.DB) error {
 		var cmd *exec.Cmd
 		switch lit := args[0].(*ast.Ident).Lit; lit {
 		case "command":
-			cmd = stdCmd(name, cmdArgs...)
+			cmd = stdCmd(s, name, cmdArgs...)
 		case "testall":
 			// I can't be confident in using such
 			// a subcommand-specific way.  Another suggestion might
 			// be like `go test all`, where 'all' is a postfix
 			// operator of './...'.
-			cmd = stdCmd(name, append([]string{"test"}, append(cmdArgs, "./...")...)...)
+			cmd = stdCmd(s, name, append([]string{"test"}, append(cmdArgs, "./...")...)...)
 		default:
-			cmd = stdCmd(name, append([]string{lit}, cmdArgs...)...)
+			cmd = stdCmd(s, name, append([]string{lit}, cmdArgs...)...)
 		}
 		return cmd.Run()
 	}
 }
 
-func stackCommand1() func([]ast.Expr, *sqlx.DB) error {
-	return func(args []ast.Expr, _ *sqlx.DB) error {
+func stackCommand1() func([]ast.Expr, typed.IO, *sqlx.DB) error {
+	return func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		name := "stack"
 		cmdArgs, err := toSlice(args[1].(ast.List))
 		if err != nil {
@@ -346,16 +593,16 @@ func stackCommand1() func([]ast.Expr, *sqlx.DB) error {
 		var cmd *exec.Cmd
 		switch lit := args[0].(*ast.Ident).Lit; lit {
 		case "command":
-			cmd = stdCmd(name, cmdArgs...)
+			cmd = stdCmd(s, name, cmdArgs...)
 		case "run":
-			if err := stdCmd(name, "build").Run(); err != nil {
+			if err := stdCmd(s, name, "build").Run(); err != nil {
 				return err
 			}
-			cmd = stdCmd(name, append([]string{"exec"}, cmdArgs...)...)
+			cmd = stdCmd(s, name, append([]string{"exec"}, cmdArgs...)...)
 		case "help":
-			cmd = stdCmd(name, "--help")
+			cmd = stdCmd(s, name, "--help")
 		default:
-			cmd = stdCmd(name, append([]string{lit}, cmdArgs...)...)
+			cmd = stdCmd(s, name, append([]string{lit}, cmdArgs...)...)
 		}
 		return cmd.Run()
 	}
@@ -386,17 +633,17 @@ var leinCommand = typed.Command{
 	Fn:     commandsInCommand("lein"),
 }
 
-func stdCmd(name string, args ...string) *exec.Cmd {
+func stdCmd(s typed.IO, name string, args ...string) *exec.Cmd {
 	cmd := exec.Command(name, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+	cmd.Stdout = s.Out
+	cmd.Stderr = s.Err
+	cmd.Stdin = s.In
 	return cmd
 }
 
-func stdExec(name string, args ...string) func([]ast.Expr, *sqlx.DB) error {
-	return func(_ []ast.Expr, _ *sqlx.DB) error {
-		return stdCmd(name, args...).Run()
+func stdExec(name string, args ...string) func([]ast.Expr, typed.IO, *sqlx.DB) error {
+	return func(_ []ast.Expr, s typed.IO, _ *sqlx.DB) error {
+		return stdCmd(s, name, args...).Run()
 	}
 }
 
@@ -420,12 +667,12 @@ func withEnv(s string, cmd *exec.Cmd) *exec.Cmd {
 
 var screenCommand = typed.Command{
 	Params: []types.Type{types.StringList},
-	Fn: func(args []ast.Expr, _ *sqlx.DB) error {
+	Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		list, err := toSlice(args[0].(ast.List))
 		if err != nil {
 			return errors.Wrap(err, "screen")
 		}
-		return withEnv("LANG=en_US.UTF-8", stdCmd("screen", list...)).Run()
+		return withEnv("LANG=en_US.UTF-8", stdCmd(s, "screen", list...)).Run()
 	},
 }
 
@@ -436,7 +683,7 @@ type execution struct {
 
 var historyCommand = typed.Command{
 	Params: []types.Type{types.String},
-	Fn: func(e []ast.Expr, db *sqlx.DB) error {
+	Fn: func(e []ast.Expr, s typed.IO, db *sqlx.DB) error {
 		var jsonFormat bool
 		var enc *json.Encoder
 		switch format := e[0].(*ast.String).Lit; format {
@@ -447,7 +694,7 @@ var historyCommand = typed.Command{
 			return fmt.Errorf("history: format %q is not supported", format)
 		}
 
-		buf := bufio.NewWriter(os.Stdout)
+		buf := bufio.NewWriter(s.Out)
 		if jsonFormat {
 			enc = json.NewEncoder(buf)
 		}
@@ -478,10 +725,10 @@ var historyCommand = typed.Command{
 }
 
 var catCommand = typed.Command{
-	Params: []types.Type{types.String},
-	Fn: func(e []ast.Expr, db *sqlx.DB) error {
-		lit := e[0].(*ast.String).Lit
-		return stdCmd("cat", lit).Run()
+	Params: []types.Type{types.Path},
+	Fn: func(e []ast.Expr, s typed.IO, db *sqlx.DB) error {
+		lit := e[0].(*ast.Path).Lit
+		return stdCmd(s, "cat", lit).Run()
 	},
 }
 
@@ -491,37 +738,53 @@ var lsCommand = typed.Command{
 }
 
 var moveCommand = typed.Command{
-	Params: []types.Type{types.String, types.String},
-	Fn: func(e []ast.Expr, _ *sqlx.DB) error {
-		s := e[0].(*ast.String).Lit
-		d := e[1].(*ast.String).Lit
-		return stdCmd("mv", s, d).Run()
+	Params: []types.Type{types.Path, types.String},
+	Fn: func(e []ast.Expr, s typed.IO, _ *sqlx.DB) error {
+		src := e[0].(*ast.Path).Lit
+		dst := e[1].(*ast.String).Lit
+		return stdCmd(s, "mv", src, dst).Run()
+	},
+}
+
+// mkdirCommand creates a directory. With {parents = true}, the missing
+// parents are created as well, and an existing directory is not an error.
+var mkdirCommand = typed.Command{
+	Params: []types.Type{
+		types.Record{Fields: []types.Field{{Name: "parents", Type: types.Bool, Optional: true}}},
+		types.String,
+	},
+	Fn: func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+		name := args[1].(*ast.String).Lit
+		if v, ok := args[0].(*ast.Record).Get("parents"); ok && v.(*ast.Bool).Value {
+			return os.MkdirAll(name, 0777)
+		}
+		return os.Mkdir(name, 0777)
 	},
 }
 
 var manCommand = typed.Command{
 	Params: []types.Type{types.String},
-	Fn: func(e []ast.Expr, _ *sqlx.DB) error {
+	Fn: func(e []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		lit := e[0].(*ast.String).Lit
-		return stdCmd("man", lit).Run()
+		return stdCmd(s, "man", lit).Run()
 	},
 }
 
 var removeCommand = typed.Command{
-	Params: []types.Type{types.String},
+	Params: []types.Type{types.Path},
 	Fn:     remove,
 }
 
-func remove(exprs []ast.Expr, _ *sqlx.DB) error {
-	s := exprs[0].(*ast.String).Lit
-	fmt.Printf("remove %s?\n", s)
+func remove(exprs []ast.Expr, s typed.IO, _ *sqlx.DB) error {
+	name := exprs[0].(*ast.Path).Lit
+	fmt.Fprintf(s.Out, "remove %s?\n", name)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	errCh := make(chan error)
 	go func() {
-		errCh <- removeFile(ctx, s)
+		errCh <- removeFile(ctx, s, name)
 	}()
 	select {
 	case err := <-errCh:
@@ -529,33 +792,33 @@ func remove(exprs []ast.Expr, _ *sqlx.DB) error {
 	}
 }
 
-func removeFile(ctx context.Context, s string) error {
+func removeFile(ctx context.Context, s typed.IO, name string) error {
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		default:
 		}
-		fmt.Println("\033[36m>\033[0m type 'y' to continue; 'i' to get information for the file; 's' to show the file; 'n' to exit")
-		fmt.Print("\033[35m>\033[0m ")
-		ans, err := read(ctx, bufio.NewReaderSize(os.Stdin, 1))
+		fmt.Fprintln(s.Out, "\033[36m>\033[0m type 'y' to continue; 'i' to get information for the file; 's' to show the file; 'n' to exit")
+		fmt.Fprint(s.Out, "\033[35m>\033[0m ")
+		ans, err := read(ctx, bufio.NewReaderSize(s.In, 1), s.Out)
 		if err != nil {
 			return err
 		}
 		switch ans {
 		case 'i':
-			fi, err := os.Stat(s)
+			fi, err := os.Stat(name)
 			if err != nil {
 				return err
 			}
-			fmt.Printf("size: %d bytes\n", fi.Size())
-			fmt.Println("is directory?:", fi.IsDir())
+			fmt.Fprintf(s.Out, "size: %d bytes\n", fi.Size())
+			fmt.Fprintln(s.Out, "is directory?:", fi.IsDir())
 		case 's':
-			f, err := os.Open(s)
+			f, err := os.Open(name)
 			if err != nil {
 				return err
 			}
-			buf := bufio.NewWriter(os.Stdout)
+			buf := bufio.NewWriter(s.Out)
 			_, err = io.Copy(buf, f)
 			if err := f.Close(); err != nil {
 				return err
@@ -567,16 +830,16 @@ func removeFile(ctx context.Context, s string) error {
 				return err
 			}
 		case 'y':
-			return os.Remove(s)
+			return os.Remove(name)
 		case 'n':
 			return nil
 		default:
-			fmt.Printf("%c is not an appropriate answer.\n", ans)
+			fmt.Fprintf(s.Out, "%c is not an appropriate answer.\n", ans)
 		}
 	}
 }
 
-func read(ctx context.Context, src io.Reader) (byte, error) {
+func read(ctx context.Context, src io.Reader, echo io.Writer) (byte, error) {
 	oldState, err := terminal.MakeRaw(0)
 	if err != nil {
 		return 0, err
@@ -584,7 +847,7 @@ func read(ctx context.Context, src io.Reader) (byte, error) {
 	defer terminal.Restore(0, oldState)
 
 	r := newReader()
-	return r.read(ctx, src)
+	return r.read(ctx, src, echo)
 }
 
 type reader struct {
@@ -595,7 +858,7 @@ func newReader() *reader {
 	return &reader{}
 }
 
-func (r *reader) read(ctx context.Context, src io.Reader) (byte, error) {
+func (r *reader) read(ctx context.Context, src io.Reader, echo io.Writer) (byte, error) {
 	ch := make(chan byte, 1)
 	errCh := make(chan error, 1)
 	go func() {
@@ -605,7 +868,7 @@ func (r *reader) read(ctx context.Context, src io.Reader) (byte, error) {
 			errCh <- err
 			return
 		}
-		fmt.Printf("%c", b1)
+		fmt.Fprintf(echo, "%c", b1)
 		for {
 			b2, err := r.readByte(src)
 			if err != nil {
@@ -617,7 +880,7 @@ func (r *reader) read(ctx context.Context, src io.Reader) (byte, error) {
 				ch <- b1
 				return
 			case editor.CharBackspace:
-				fmt.Print("\033[D\033[K")
+				fmt.Fprint(echo, "\033[D\033[K")
 				goto F
 			}
 		}
@@ -628,7 +891,7 @@ func (r *reader) read(ctx context.Context, src io.Reader) (byte, error) {
 	case err := <-errCh:
 		return 0, err
 	case b := <-ch:
-		fmt.Println("\r")
+		fmt.Fprintln(echo, "\r")
 		if b == editor.CharCtrlC {
 			return 0, errors.New("interrupted")
 		}
@@ -660,9 +923,9 @@ func (r *reader) readByte(src io.Reader) (byte, error) {
 
 var cnpCommand = typed.Command{
 	Params: []types.Type{types.String},
-	Fn: func(e []ast.Expr, _ *sqlx.DB) error {
+	Fn: func(e []ast.Expr, s typed.IO, _ *sqlx.DB) error {
 		lit := e[0].(*ast.String).Lit
-		return stdCmd("create-new-project", lit).Run()
+		return stdCmd(s, "create-new-project", lit).Run()
 	},
 }
 
@@ -2,12 +2,19 @@ package extra
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/jmoiron/sqlx"
 
 	"github.com/elpinal/coco3/extra/ast"
+	"github.com/elpinal/coco3/extra/parser"
 	"github.com/elpinal/coco3/extra/token"
 	"github.com/elpinal/coco3/extra/typed"
 	"github.com/elpinal/coco3/extra/types"
@@ -16,7 +23,7 @@ import (
 func TestEval(t *testing.T) {
 	var buf bytes.Buffer
 	prefix := "print: the argument is"
-	printCommand := func(args []ast.Expr, _ *sqlx.DB) error {
+	printCommand := func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
 		_, err := fmt.Fprintln(&buf, prefix, args[0].(*ast.String).Lit)
 		return err
 	}
@@ -32,3 +39,335 @@ func TestEval(t *testing.T) {
 		t.Errorf("Eval: want %q, but got %q", want, got)
 	}
 }
+
+func TestDef(t *testing.T) {
+	var got []string
+	e := WithoutDefault()
+	e.Bind("print", typed.Command{
+		Params: []types.Type{types.String, types.StringList},
+		Fn: func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+			xs, err := toSlice(args[1].(ast.List))
+			if err != nil {
+				return err
+			}
+			got = append(got, args[0].(*ast.String).Lit+fmt.Sprint(xs))
+			return nil
+		},
+	})
+	e.Bind("sub", typed.Command{
+		Params: []types.Type{types.Ident},
+		Fn: func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+			got = append(got, args[0].(*ast.Ident).Lit)
+			return nil
+		},
+	})
+	name := func(lit string) token.Token { return token.Token{Lit: lit} }
+	stmts := []ast.Stmt{
+		&ast.Def{Name: name("x"), Expr: &ast.String{Lit: "a"}},
+		&ast.Def{Name: name("xs"), Expr: &ast.Cons{Head: &ast.String{Lit: "b"}, Tail: &ast.Empty{}}},
+		&ast.Def{Name: name("y"), Expr: &ast.Ident{Lit: "x"}},
+		&ast.Command{Name: name("print"), Args: []ast.Expr{&ast.Ident{Lit: "y"}, &ast.Ident{Lit: "xs"}}},
+		// Identifiers expected by the command are not references.
+		&ast.Command{Name: name("sub"), Args: []ast.Expr{&ast.Ident{Lit: "x"}}},
+	}
+	for _, stmt := range stmts {
+		if err := e.Eval(stmt); err != nil {
+			t.Fatalf("Eval(%v): %v", stmt, err)
+		}
+	}
+	if want := []string{"a[b]", "x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if v, ok := e.Lookup("y"); !ok || v.Type() != types.String {
+		t.Errorf("Lookup(%q) = %v, %v; want a String", "y", v, ok)
+	}
+
+	for _, stmt := range []ast.Stmt{
+		&ast.Command{Name: name("print"), Args: []ast.Expr{&ast.Ident{Lit: "xs"}, &ast.Ident{Lit: "xs"}}},
+		&ast.Command{Name: name("print"), Args: []ast.Expr{&ast.Ident{Lit: "z"}, &ast.Ident{Lit: "xs"}}},
+		&ast.Def{Name: name("z"), Expr: &ast.Ident{Lit: "w"}},
+	} {
+		if err := e.Eval(stmt); err == nil {
+			t.Errorf("Eval(%v): unexpectedly succeeded", stmt)
+		}
+	}
+}
+
+func TestFn(t *testing.T) {
+	var got []string
+	e := WithoutDefault()
+	e.Bind("git", typed.Command{
+		Params: []types.Type{types.Ident, types.StringList},
+		Fn: func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+			xs, err := toSlice(args[1].(ast.List))
+			if err != nil {
+				return err
+			}
+			got = append(got, args[0].(*ast.Ident).Lit+fmt.Sprint(xs))
+			return nil
+		},
+	})
+	e.Bind("twice", typed.Command{
+		Params: []types.Type{types.Func{Param: types.StringList, Result: types.Command}},
+		Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
+			for _, lit := range []string{"1", "2"} {
+				a, err := args[0].(*typed.Func).Apply(&ast.Cons{Head: &ast.String{Lit: lit}, Tail: &ast.Empty{}})
+				if err != nil {
+					return err
+				}
+				if err := a.(*typed.Action).Run(s); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	for _, src := range []string{
+		"def gst = git status",
+		"gst ['-s']",
+		"def g = fn (sub : Ident) -> git sub",
+		"g log []",
+		"def id = fn (x : List String) -> x",
+		"git diff (id ['a'])",
+		"twice gst",
+		"twice (g show)",
+		"def st = git status []",
+		"st",
+	} {
+		stmt, err := parser.ParseStmt([]byte(src))
+		if err != nil {
+			t.Fatalf("ParseStmt(%q): %v", src, err)
+		}
+		if err := e.Eval(stmt); err != nil {
+			t.Fatalf("Eval(%q): %v", src, err)
+		}
+	}
+	want := []string{"status[-s]", "log[]", "diff[a]", "status[1]", "status[2]", "show[1]", "show[2]", "status[]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for name, typ := range map[string]string{
+		"gst": "List String -> Command",
+		"g":   "Ident -> List String -> Command",
+		"id":  "List String -> List String",
+		"st":  "Command",
+	} {
+		if v, _ := e.Lookup(name); v == nil || v.Type().String() != typ {
+			t.Errorf("type of %s: got %v, want %s", name, v, typ)
+		}
+	}
+
+	for _, src := range []string{
+		"gst",
+		"gst 'a'",
+		"id []",
+		"twice git",
+		"def h = gst [] []",
+		"def h = fn (x : String) -> git status x",
+		"def h = fn (x : Int) -> y",
+	} {
+		stmt, err := parser.ParseStmt([]byte(src))
+		if err != nil {
+			t.Fatalf("ParseStmt(%q): %v", src, err)
+		}
+		if err := e.Eval(stmt); err == nil {
+			t.Errorf("Eval(%q): unexpectedly succeeded", src)
+		}
+	}
+}
+
+func TestTypes(t *testing.T) {
+	f, err := ioutil.TempFile("", "coco3-extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	var got []string
+	record := func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+		got = append(got, fmt.Sprint(args[0]))
+		return nil
+	}
+	e := New(Option{})
+	a := types.Var{Name: "a"}
+	e.Bind("show", typed.Command{Params: []types.Type{a}, Fn: record})
+	e.Bind("len", typed.Command{Params: []types.Type{types.List{Elem: a}}, Fn: func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+		got = append(got, fmt.Sprint(args[0].(ast.List).Length()))
+		return nil
+	}})
+	e.Bind("open", typed.Command{Params: []types.Type{types.Path}, Fn: func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+		got = append(got, fmt.Sprintf("%T", args[0]))
+		return nil
+	}})
+	e.Bind("opts", typed.Command{
+		Params: []types.Type{types.Record{Fields: []types.Field{
+			{Name: "n", Type: types.Int},
+			{Name: "v", Type: types.Bool, Optional: true},
+		}}},
+		Fn: func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+			r := args[0].(*ast.Record)
+			n, _ := r.Get("n")
+			v, ok := r.Get("v")
+			if !ok {
+				v = &ast.Bool{Value: false}
+			}
+			got = append(got, fmt.Sprint(n, v))
+			return nil
+		},
+	})
+	for _, src := range []string{
+		"show [1, 2]",
+		"show true",
+		"show [[], ['a']]",
+		"len []",
+		"len [true, false]",
+		"len (1 : [])",
+		"def xs = ['b', 'c']",
+		"show xs",
+		"show ('a' : ['b', 'c'])",
+		"open '" + f.Name() + "'",
+		"opts {n = 1}",
+		"opts {v = true, n = 2}",
+		"repeat 2 (show 'r')",
+		"def id = fn (x : a) -> x",
+		"show (id 3)",
+		"show (id 'x')",
+		"def p = '" + f.Name() + "'",
+		"open p",
+		"def q = '" + f.Name() + ".nosuch'",
+	} {
+		stmt, err := parser.ParseStmt([]byte(src))
+		if err != nil {
+			t.Fatalf("ParseStmt(%q): %v", src, err)
+		}
+		if err := e.Eval(stmt); err != nil {
+			t.Fatalf("Eval(%q): %v", src, err)
+		}
+	}
+	want := []string{
+		"[1, 2]", "true", `[[], ["a"]]`, "0", "2", "1", `["b", "c"]`, `["a", "b", "c"]`,
+		"*ast.Path", "1 false", "2 true", `"r"`, `"r"`, "3", `"x"`, "*ast.Path",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, src := range []string{
+		"show [1, 'a']",
+		"show ('a' : [1])",
+		"len 'a'",
+		"open '" + f.Name() + ".nosuch'",
+		"open (id 'a')",
+		"opts {}",
+		"opts {n = 1, w = true}",
+		"opts {n = true}",
+		"repeat 2 'ls' []",
+		"show (id 1 2)",
+		"open q",
+		"open xs",
+	} {
+		stmt, err := parser.ParseStmt([]byte(src))
+		if err != nil {
+			t.Fatalf("ParseStmt(%q): %v", src, err)
+		}
+		if err := e.Eval(stmt); err == nil {
+			t.Errorf("Eval(%q): unexpectedly succeeded", src)
+		}
+	}
+}
+
+func TestMismatchNames(t *testing.T) {
+	// Type variables are shown by the names in the source or by letters,
+	// not by the fresh variables made by the checker.
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"exec [] []", "type mismatch: (List a) (type of []) does not match with (String) (expected type)"},
+		{"exec (fn (x : b) -> x) []", "type mismatch: (b -> b) (type of (fn (x : b) -> \"x\")) does not match with (String) (expected type)"},
+	}
+	e := New(Option{})
+	for _, test := range tests {
+		stmt, err := parser.ParseStmt([]byte(test.src))
+		if err != nil {
+			t.Fatalf("ParseStmt(%q): %v", test.src, err)
+		}
+		err = e.Eval(stmt)
+		if err == nil {
+			t.Errorf("Eval(%q): unexpectedly succeeded", test.src)
+			continue
+		}
+		pe, ok := err.(*parser.ParseError)
+		if !ok || pe.Msg != test.want {
+			t.Errorf("Eval(%q): got %v, want %q", test.src, err, test.want)
+		}
+	}
+}
+
+func TestPipe(t *testing.T) {
+	var out, errOut bytes.Buffer
+	e := New(Option{IO: typed.IO{In: strings.NewReader("in\n"), Out: &out, Err: &errOut}})
+	e.Bind("emit", typed.Command{
+		Params: []types.Type{types.String},
+		Fn: func(args []ast.Expr, s typed.IO, _ *sqlx.DB) error {
+			_, err := io.WriteString(s.Out, args[0].(*ast.String).Lit)
+			return err
+		},
+	})
+	e.Bind("upper", typed.Command{
+		Params: []types.Type{},
+		Fn: func(_ []ast.Expr, s typed.IO, _ *sqlx.DB) error {
+			b, err := ioutil.ReadAll(s.In)
+			if err != nil {
+				return err
+			}
+			_, err = s.Out.Write(bytes.ToUpper(b))
+			return err
+		},
+	})
+	e.Bind("fail", typed.Command{
+		Params: []types.Type{},
+		Fn: func(_ []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+			return errors.New("failed")
+		},
+	})
+	tests := []struct {
+		src  string
+		out  string
+		err  string
+		fail bool
+	}{
+		{src: "upper", out: "IN\n"},
+		{src: "emit 'a' | upper", out: "A"},
+		{src: "emit 'ab' |\n  upper | exec 'cat' []", out: "AB"},
+		{src: "exec 'echo' ['x'] | upper", out: "X\n"},
+		{src: "upper | emit 'b'", out: "b"},
+		{src: "fail | emit 'c'", out: "c", err: "failed\n"},
+		{src: "emit 'd' | fail", fail: true},
+		{src: "emit 'e' | nosuch", fail: true},
+		{src: "emit 'f' | emit 1", fail: true},
+	}
+	for _, test := range tests {
+		out.Reset()
+		errOut.Reset()
+		stmt, err := parser.ParseStmt([]byte(test.src))
+		if err != nil {
+			t.Fatalf("ParseStmt(%q): %v", test.src, err)
+		}
+		err = e.Eval(stmt)
+		if test.fail {
+			if err == nil {
+				t.Errorf("Eval(%q): unexpectedly succeeded", test.src)
+			}
+		} else if err != nil {
+			t.Errorf("Eval(%q): %v", test.src, err)
+		}
+		if got := out.String(); got != test.out {
+			t.Errorf("Eval(%q): output: got %q, want %q", test.src, got, test.out)
+		}
+		if got := errOut.String(); got != test.err {
+			t.Errorf("Eval(%q): error output: got %q, want %q", test.src, got, test.err)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package extra
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/elpinal/coco3/extra/ast"
+	"github.com/elpinal/coco3/extra/typed"
+	"github.com/elpinal/coco3/extra/types"
+)
+
+// lookup returns the value named name: a definition, or a typed command.
+func (e *Env) lookup(name string) (ast.Expr, bool) {
+	if v, ok := e.vars[name]; ok {
+		return v, true
+	}
+	if c, ok := e.cmds[name]; ok {
+		return c.Value(e.DB), true
+	}
+	return nil, false
+}
+
+// isStrings reports whether v is a string or a list of strings.
+func isStrings(v ast.Expr) bool {
+	switch v := v.(type) {
+	case *ast.String:
+		return true
+	case *ast.Cons:
+		_, ok := v.Head.(*ast.String)
+		return ok && isStrings(v.Tail)
+	case *ast.Empty:
+		return true
+	}
+	return false
+}
+
+// split splits the curried type t into the parameters and the final result.
+func split(t types.Type) (params []types.Type, result types.Type) {
+	for {
+		f, ok := t.(types.Func)
+		if !ok {
+			return params, t
+		}
+		params = append(params, f.Param)
+		t = f.Result
+	}
+}
+
+// mismatch returns a type mismatch error. The fresh type variables in t and
+// want are renamed by readable.
+func mismatch(arg ast.Expr, t, want types.Type, origin map[string]string) error {
+	r := readable(origin, t, want)
+	return fmt.Errorf("type mismatch: (%v) (type of %v) does not match with (%v) (expected type)", r.Apply(t), arg, r.Apply(want))
+}
+
+// readable returns the substitution which renames the fresh type variables
+// in ts to the names they were instantiated from, given by origin, or else
+// to unused letters.
+func readable(origin map[string]string, ts ...types.Type) types.Subst {
+	var fresh []string
+	used := make(map[string]bool)
+	for _, t := range ts {
+		for _, name := range types.Vars(t) {
+			if !isFresh(name) {
+				used[name] = true
+			} else if !used[name] {
+				used[name] = true
+				fresh = append(fresh, name)
+			}
+		}
+	}
+	s := make(types.Subst)
+	i := 0
+	for _, name := range fresh {
+		n, ok := origin[name]
+		for !ok || used[n] {
+			n, ok = varName(i), true
+			i++
+		}
+		used[n] = true
+		s[name] = types.Var{Name: n}
+	}
+	return s
+}
+
+// varName returns the i-th name of type variables: a, b, ..., z, t26, ...
+func varName(i int) string {
+	if i < 26 {
+		return string(rune('a' + i))
+	}
+	return "t" + strconv.Itoa(i)
+}
+
+// A ref is a reference to a parameter, a definition or a typed command.
+type ref struct {
+	name string
+}
+
+func (_ *ref) Expr() {}
+
+func (_ *ref) Type() types.Type {
+	return nil
+}
+
+func (r *ref) String() string {
+	return r.name
+}
+
+// A lambda is a type-checked function expression.
+type lambda struct {
+	param string
+	body  ast.Expr
+	t     types.Func
+	c     *checker
+}
+
+func (_ *lambda) Expr() {}
+
+func (_ *lambda) Type() types.Type {
+	return nil
+}
+
+// A checker infers the types of expressions by unification. Checking an
+// expression results in the expression to evaluate, in which the identifiers
+// are resolved and the strings given as paths are checked.
+type checker struct {
+	e     *Env
+	subst types.Subst
+	n     int // the number of fresh type variables
+
+	// origin maps the fresh type variables made by instantiate to the
+	// names they replace, for error messages.
+	origin map[string]string
+}
+
+func (e *Env) newChecker() *checker {
+	return &checker{e: e, subst: make(types.Subst), origin: make(map[string]string)}
+}
+
+// fresh returns a new type variable. Its name cannot be written in types.
+func (c *checker) fresh() types.Var {
+	c.n++
+	return types.Var{Name: "_t" + strconv.Itoa(c.n)}
+}
+
+// isFresh reports whether name is the name of a fresh type variable.
+func isFresh(name string) bool {
+	return strings.HasPrefix(name, "_t")
+}
+
+// instantiate replaces the type variables of t with fresh ones. The types
+// of definitions may contain the fresh variables of other checkers, which
+// are skipped so that no variable is replaced with itself.
+func (c *checker) instantiate(t types.Type) types.Type {
+	names := types.Vars(t)
+	used := make(map[string]bool, len(names))
+	for _, name := range names {
+		used[name] = true
+	}
+	s := make(types.Subst)
+	for _, name := range names {
+		v := c.fresh()
+		for used[v.Name] {
+			v = c.fresh()
+		}
+		s[name] = v
+		if !isFresh(name) {
+			c.origin[v.Name] = name
+		}
+	}
+	return s.Apply(t)
+}
+
+func (c *checker) apply(t types.Type) types.Type {
+	if t == nil {
+		return nil
+	}
+	return c.subst.Apply(t)
+}
+
+// unify unifies the type t of arg with want, or returns a type mismatch
+// error.
+func (c *checker) unify(arg ast.Expr, t, want types.Type) error {
+	if err := types.Unify(t, want, c.subst); err != nil {
+		return mismatch(arg, c.apply(t), c.apply(want), c.origin)
+	}
+	return nil
+}
+
+// check returns the expression to evaluate for x and its type, where want
+// is the expected type or nil and scope holds the types of the parameters
+// of the enclosing functions.
+//
+// An identifier is a reference to a parameter, a definition or a typed
+// command, except that an identifier expected to be of type Ident, e.g. a
+// subcommand of git, is itself unless it names a parameter.
+func (c *checker) check(x ast.Expr, want types.Type, scope map[string]types.Type) (ast.Expr, types.Type, error) {
+	want = c.apply(want)
+	switch x := x.(type) {
+	case *ast.Ident:
+		if t, ok := scope[x.Lit]; ok {
+			return &ref{name: x.Lit}, t, nil
+		}
+		if want == types.Ident {
+			return x, types.Ident, nil
+		}
+		v, ok := c.e.lookup(x.Lit)
+		if !ok {
+			return nil, nil, fmt.Errorf("undefined: %s", x.Lit)
+		}
+		if isStrings(v) && (want == types.Path || types.Equal(want, types.List{Elem: types.Path})) {
+			// A string bound by a definition is checked as a path
+			// where a path is expected, as is a string literal.
+			return c.check(v, want, scope)
+		}
+		return &ref{name: x.Lit}, c.instantiate(v.Type()), nil
+	case *ast.String:
+		if want != types.Path {
+			return x, types.String, nil
+		}
+		if _, err := os.Stat(x.Lit); err != nil {
+			return nil, nil, err
+		}
+		return &ast.Path{Lit: x.Lit}, types.Path, nil
+	case *ast.Empty:
+		return x, types.List{Elem: c.fresh()}, nil
+	case *ast.Cons:
+		elem := c.fresh()
+		if l, ok := want.(types.List); ok {
+			c.subst[elem.Name] = l.Elem
+		}
+		head, t, err := c.check(x.Head, elem, scope)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := c.unify(x.Head, t, elem); err != nil {
+			return nil, nil, err
+		}
+		lt := types.List{Elem: elem}
+		tail, t, err := c.check(x.Tail, lt, scope)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := c.unify(x.Tail, t, lt); err != nil {
+			return nil, nil, err
+		}
+		return &ast.Cons{Head: head, Tail: tail.(ast.List)}, c.apply(lt), nil
+	case *ast.Record:
+		r := &ast.Record{Fields: make([]ast.RecordField, len(x.Fields))}
+		fs := make([]types.Field, len(x.Fields))
+		for i, f := range x.Fields {
+			var ft types.Type
+			if w, ok := want.(types.Record); ok {
+				wf, _ := w.Field(f.Name.Lit)
+				ft = wf.Type
+			}
+			v, t, err := c.check(f.Value, ft, scope)
+			if err != nil {
+				return nil, nil, err
+			}
+			r.Fields[i] = ast.RecordField{Name: f.Name, Value: v}
+			fs[i] = types.Field{Name: f.Name.Lit, Type: t}
+		}
+		return r, types.Record{Fields: fs}, nil
+	case *ast.App:
+		_, t, err := c.check(&ast.Ident{Lit: x.Func.Lit}, nil, scope)
+		if err != nil {
+			return nil, nil, err
+		}
+		app := &ast.App{Func: x.Func, Args: make([]ast.Expr, len(x.Args))}
+		for i, arg := range x.Args {
+			f, ok := c.apply(t).(types.Func)
+			if !ok {
+				v, ok := c.apply(t).(types.Var)
+				if !ok {
+					return nil, nil, fmt.Errorf("too many arguments to %s", x.Func.Lit)
+				}
+				f = types.Func{Param: c.fresh(), Result: c.fresh()}
+				c.subst[v.Name] = f
+			}
+			a, at, err := c.check(arg, f.Param, scope)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := c.unify(arg, at, f.Param); err != nil {
+				return nil, nil, err
+			}
+			app.Args[i] = a
+			t = f.Result
+		}
+		return app, c.apply(t), nil
+	case *ast.Fn:
+		pt := c.instantiate(x.ParamType)
+		s := make(map[string]types.Type, len(scope)+1)
+		for k, v := range scope {
+			s[k] = v
+		}
+		s[x.Param.Lit] = pt
+		body, t, err := c.check(x.Body, nil, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		f := types.Func{Param: pt, Result: t}
+		return &lambda{param: x.Param.Lit, body: body, t: f, c: c}, f, nil
+	}
+	t := x.Type()
+	if t == nil {
+		return nil, nil, fmt.Errorf("unknown type of %v", x)
+	}
+	return x, c.instantiate(t), nil
+}
+
+// eval returns the value of x, which is the result of check. Scope holds
+// the arguments of the enclosing functions.
+func (e *Env) eval(x ast.Expr, scope map[string]ast.Expr) (ast.Expr, error) {
+	switch x := x.(type) {
+	case *ref:
+		if v, ok := scope[x.name]; ok {
+			return v, nil
+		}
+		v, ok := e.lookup(x.name)
+		if !ok {
+			return nil, fmt.Errorf("undefined: %s", x.name)
+		}
+		return v, nil
+	case *ast.Cons:
+		head, err := e.eval(x.Head, scope)
+		if err != nil {
+			return nil, err
+		}
+		tail, err := e.eval(x.Tail, scope)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.Cons{Head: head, Tail: tail.(ast.List)}, nil
+	case *ast.Record:
+		r := &ast.Record{Fields: make([]ast.RecordField, len(x.Fields))}
+		for i, f := range x.Fields {
+			v, err := e.eval(f.Value, scope)
+			if err != nil {
+				return nil, err
+			}
+			r.Fields[i] = ast.RecordField{Name: f.Name, Value: v}
+		}
+		return r, nil
+	case *ast.App:
+		v, err := e.eval(&ref{name: x.Func.Lit}, scope)
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range x.Args {
+			f, ok := v.(*typed.Func)
+			if !ok {
+				return nil, fmt.Errorf("too many arguments to %s", x.Func.Lit)
+			}
+			a, err := e.eval(arg, scope)
+			if err != nil {
+				return nil, err
+			}
+			v, err = f.Apply(a)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case *lambda:
+		t := x.c.apply(x.t).(types.Func)
+		return typed.NewFunc(t, func(arg ast.Expr) (ast.Expr, error) {
+			if !types.Assignable(arg.Type(), t.Param) {
+				return nil, mismatch(arg, arg.Type(), t.Param, x.c.origin)
+			}
+			s := make(map[string]ast.Expr, len(scope)+1)
+			for k, v := range scope {
+				s[k] = v
+			}
+			s[x.param] = arg
+			return e.eval(x.body, s)
+		}), nil
+	}
+	return x, nil
+}
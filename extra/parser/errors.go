@@ -16,9 +16,15 @@ type ParseError struct {
 	Msg string
 
 	Src string
+
+	// File is the name of the source, or empty if unknown.
+	File string
 }
 
 func (p *ParseError) Error() string {
+	if p.File != "" {
+		return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Msg)
+	}
 	return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Msg)
 }
 
@@ -26,8 +26,16 @@ type exprLexer struct {
 	tokLine   uint
 	tokColumn uint
 
+	// depth is the number of the brackets enclosing the current character.
+	// Newlines in brackets do not separate statements.
+	depth int
+
+	// cont is true if the last token cannot end a statement, e.g. "->", so
+	// that a newline following it does not separate statements.
+	cont bool
+
 	// result
-	expr *ast.Command
+	stmts []ast.Stmt
 
 	// channel for error
 	errCh chan *ParseError
@@ -68,6 +76,15 @@ func (l *exprLexer) emitError(format string, args ...interface{}) {
 	}
 }
 
+// errorAt reports an error at tok, like Error.
+func (l *exprLexer) errorAt(tok token.Token, format string, args ...interface{}) {
+	l.errCh <- &ParseError{
+		Line:   tok.Line,
+		Column: tok.Column,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
 func (l *exprLexer) errorAtHere(format string, args ...interface{}) *ParseError {
 	return &ParseError{
 		Line:   l.line,
@@ -77,6 +94,20 @@ func (l *exprLexer) errorAtHere(format string, args ...interface{}) *ParseError
 }
 
 func (l *exprLexer) Lex(yylval *yySymType) int {
+	t := l.lex(yylval)
+	switch t {
+	case '(', LBRACK, LBRACE:
+		l.depth++
+	case ')', RBRACK, RBRACE:
+		if l.depth > 0 {
+			l.depth--
+		}
+	}
+	l.cont = t == ARROW || t == '=' || t == COLON || t == '|'
+	return t
+}
+
+func (l *exprLexer) lex(yylval *yySymType) int {
 	for {
 		l.tokLine = l.line
 		l.tokColumn = l.column
@@ -85,8 +116,18 @@ func (l *exprLexer) Lex(yylval *yySymType) int {
 		switch c {
 		case eof:
 			return eof
-		case ' ', '\n':
+		case ' ':
+			l.next()
+		case '\n':
 			l.next()
+			if l.depth == 0 && !l.cont {
+				yylval.token = token.Token{
+					Lit:    "\n",
+					Line:   l.tokLine,
+					Column: l.tokColumn,
+				}
+				return ';'
+			}
 		case '\'':
 			l.next()
 			return l.str(yylval)
@@ -96,13 +137,32 @@ func (l *exprLexer) Lex(yylval *yySymType) int {
 		case ']':
 			l.next()
 			return RBRACK
+		case '{':
+			l.next()
+			return LBRACE
+		case '}':
+			l.next()
+			return RBRACE
 		case ':':
 			l.next()
 			return COLON
 		case ',':
 			l.next()
 			return COMMA
-		case '!', '(', ')':
+		case '-':
+			if len(l.src) > 0 && l.src[0] == '>' {
+				l.next()
+				l.next()
+				yylval.token = token.Token{
+					Lit:    "->",
+					Line:   l.tokLine,
+					Column: l.tokColumn,
+				}
+				return ARROW
+			}
+			l.emitError("invalid character: %[1]U %[1]q", c)
+			return ILLEGAL
+		case '!', '(', ')', '=', '?', ';', '|':
 			l.next()
 			yylval.token = token.Token{
 				Lit:    string(c),
@@ -130,6 +190,10 @@ func (l *exprLexer) ident(yylval *yySymType) int {
 		return DEF
 	case "fn":
 		return FN
+	case "true":
+		return TRUE
+	case "false":
+		return FALSE
 	}
 	return IDENT
 }
@@ -215,6 +279,17 @@ func (l *exprLexer) takeWhile(kind types.Type, f func(rune) bool, yylval *yySymT
 }
 
 func (l *exprLexer) next() {
+	if l.off > 0 && l.r == eof {
+		return
+	}
+	// A newline is at the end of its line, so that the errors at the end
+	// of a statement point at its line.
+	if l.r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
 	if len(l.src) == 0 {
 		l.r = eof
 		return
@@ -222,18 +297,11 @@ func (l *exprLexer) next() {
 	c, size := utf8.DecodeRune(l.src)
 	l.src = l.src[size:]
 	l.off++
-	if c == '\n' {
-		l.line++
-		l.column = 0
-	} else {
-		l.column++
-	}
+	l.r = c
 	if c == utf8.RuneError && size == 1 {
 		l.emitError("next: invalid utf8")
 		l.next()
-		return
 	}
-	l.r = c
 }
 
 func (l *exprLexer) Error(s string) {
@@ -257,7 +325,60 @@ func init() {
 	yyErrorVerbose = true
 }
 
+// Parse parses src as a command. It returns nil if src is empty.
 func Parse(src []byte) (*ast.Command, error) {
+	stmt, err := ParseStmt(src)
+	if err != nil {
+		return nil, err
+	}
+	switch x := stmt.(type) {
+	case nil:
+		return nil, nil
+	case *ast.Command:
+		return x, nil
+	case *ast.Pipe:
+		return nil, &ParseError{
+			Line:   x.Commands[0].Name.Line,
+			Column: x.Commands[0].Name.Column,
+			Msg:    "expected command, found pipe",
+			Src:    string(src),
+		}
+	case *ast.Def:
+		return nil, &ParseError{
+			Line:   x.Name.Line,
+			Column: x.Name.Column,
+			Msg:    "expected command, found definition",
+			Src:    string(src),
+		}
+	}
+	panic("unreachable")
+}
+
+// ParseStmt parses src as a command or a definition. It returns nil if src
+// is empty.
+func ParseStmt(src []byte) (ast.Stmt, error) {
+	stmts, err := ParseProgram(src)
+	if err != nil {
+		return nil, err
+	}
+	switch len(stmts) {
+	case 0:
+		return nil, nil
+	case 1:
+		return stmts[0], nil
+	}
+	name := stmtName(stmts[1])
+	return nil, &ParseError{
+		Line:   name.Line,
+		Column: name.Column,
+		Msg:    "expected one statement, found more",
+		Src:    string(src),
+	}
+}
+
+// ParseProgram parses src as a sequence of commands and definitions, which
+// are separated by newlines or semicolons. Empty statements are omitted.
+func ParseProgram(src []byte) ([]ast.Stmt, error) {
 	l := newLexer(src)
 	done := l.run()
 	select {
@@ -266,5 +387,18 @@ func Parse(src []byte) (*ast.Command, error) {
 		return nil, err
 	case <-done:
 	}
-	return l.expr, nil
+	return l.stmts, nil
+}
+
+// stmtName returns the name of the command or the definition.
+func stmtName(stmt ast.Stmt) token.Token {
+	switch x := stmt.(type) {
+	case *ast.Command:
+		return x.Name
+	case *ast.Pipe:
+		return x.Commands[0].Name
+	case *ast.Def:
+		return x.Name
+	}
+	panic("unreachable")
 }
@@ -1,15 +1,20 @@
+// Code generated by goyacc -o parser.go parser.y. DO NOT EDIT.
+
 //line parser.y:2
+
 package parser
 
 import __yyfmt__ "fmt"
 
 //line parser.y:3
+
 import (
 	"github.com/elpinal/coco3/extra/ast"
 	"github.com/elpinal/coco3/extra/token"
+	"github.com/elpinal/coco3/extra/types"
 )
 
-//line parser.y:12
+//line parser.y:13
 type yySymType struct {
 	yys     int
 	token   token.Token
@@ -18,6 +23,15 @@ type yySymType struct {
 	expr    ast.Expr
 	list    ast.List
 	def     *ast.Def
+	stmt    ast.Stmt
+	stmts   []ast.Stmt
+	pipe    *ast.Pipe
+	typ     types.Type
+	record  *ast.Record
+	fields  []ast.RecordField
+	field   ast.RecordField
+	ftypes  []types.Field
+	ftype   types.Field
 }
 
 const ILLEGAL = 57346
@@ -30,6 +44,11 @@ const COLON = 57352
 const COMMA = 57353
 const DEF = 57354
 const FN = 57355
+const ARROW = 57356
+const LBRACE = 57357
+const RBRACE = 57358
+const TRUE = 57359
+const FALSE = 57360
 
 var yyToknames = [...]string{
 	"$end",
@@ -47,14 +66,25 @@ var yyToknames = [...]string{
 	"DEF",
 	"'='",
 	"FN",
+	"ARROW",
+	"'('",
+	"')'",
+	"LBRACE",
+	"RBRACE",
+	"'?'",
+	"TRUE",
+	"FALSE",
+	"';'",
+	"'|'",
 }
+
 var yyStatenames = [...]string{}
 
 const yyEofCode = 1
 const yyErrCode = 2
 const yyInitialStackSize = 16
 
-//line parser.y:119
+//line parser.y:309
 
 //line yacctab:1
 var yyExca = [...]int{
@@ -65,61 +95,105 @@ var yyExca = [...]int{
 
 const yyPrivate = 57344
 
-const yyLast = 25
+const yyLast = 113
 
 var yyAct = [...]int{
-
-	16, 11, 3, 10, 8, 12, 21, 9, 17, 4,
-	14, 17, 20, 15, 19, 12, 18, 5, 6, 7,
-	2, 1, 22, 0, 13,
+	64, 21, 75, 34, 45, 66, 15, 42, 23, 22,
+	13, 12, 11, 47, 77, 82, 55, 81, 70, 52,
+	49, 79, 35, 33, 57, 36, 24, 31, 44, 25,
+	78, 71, 35, 43, 40, 38, 20, 30, 48, 32,
+	67, 83, 27, 28, 9, 56, 73, 54, 50, 51,
+	89, 10, 68, 7, 69, 58, 43, 9, 68, 62,
+	69, 61, 60, 84, 10, 8, 18, 19, 3, 74,
+	63, 39, 80, 72, 53, 26, 24, 31, 41, 25,
+	17, 35, 85, 86, 47, 88, 87, 30, 59, 32,
+	90, 16, 27, 28, 26, 24, 31, 14, 25, 1,
+	65, 2, 6, 76, 46, 29, 30, 37, 32, 5,
+	4, 27, 28,
 }
-var yyPact = [...]int{
 
-	-3, -1000, -1000, -1000, 13, -2, -1000, -1000, 0, -1000,
-	-1000, -1000, 5, -2, 8, -1000, 4, -5, -1000, 0,
-	-1000, 2, -1000,
+var yyPact = [...]int{
+	52, -1000, -12, -1000, -1000, -14, -1000, -15, 92, -1000,
+	86, 52, 39, 39, 22, 89, -1000, -1000, -1000, -1000,
+	20, -1000, 61, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	20, 70, 8, 89, -1000, -1000, 89, -1000, 3, 89,
+	1, -1000, 66, 36, -1000, -4, 34, 10, -1000, 83,
+	-1000, 61, -1000, -1000, 89, -1000, 79, 89, 89, 60,
+	-1000, -1000, -1000, 35, 0, 15, -1000, 41, 35, 9,
+	5, 35, -1000, -1000, -1, -5, 30, 53, 77, 20,
+	-1000, -1000, -1000, 9, 35, 40, -1000, -1000, -1000, 35,
+	-1000,
 }
-var yyPgo = [...]int{
 
-	0, 21, 20, 17, 19, 1, 0, 19,
+var yyPgo = [...]int{
+	0, 110, 53, 109, 6, 1, 9, 3, 107, 8,
+	7, 105, 4, 104, 2, 103, 102, 68, 101, 0,
+	100, 5, 99,
 }
-var yyR1 = [...]int{
 
-	0, 1, 2, 2, 2, 4, 4, 4, 4, 3,
-	3, 5, 5, 5, 6, 6, 7,
+var yyR1 = [...]int{
+	0, 22, 18, 18, 17, 17, 17, 3, 3, 1,
+	1, 2, 2, 5, 5, 6, 6, 6, 6, 6,
+	6, 6, 7, 7, 7, 8, 19, 19, 20, 20,
+	21, 21, 21, 14, 14, 15, 15, 4, 4, 9,
+	9, 9, 10, 10, 11, 11, 12, 12, 13, 16,
 }
-var yyR2 = [...]int{
 
-	0, 1, 0, 2, 3, 1, 1, 1, 1, 0,
-	2, 2, 3, 3, 1, 3, 4,
+var yyR2 = [...]int{
+	0, 1, 1, 3, 1, 1, 1, 3, 3, 0,
+	1, 2, 3, 1, 1, 1, 1, 1, 1, 1,
+	1, 3, 1, 3, 1, 8, 1, 3, 1, 2,
+	1, 3, 3, 1, 3, 3, 4, 0, 2, 2,
+	3, 3, 1, 3, 2, 3, 1, 3, 3, 4,
 }
-var yyChk = [...]int{
 
-	-1000, -1, -2, 5, 12, -3, 5, -4, 6, 9,
-	5, -5, 7, -3, 10, 8, -6, 6, -5, 6,
-	8, 11, -6,
+var yyChk = [...]int{
+	-1000, -22, -18, -17, -1, -3, -16, -2, 13, 5,
+	12, 24, 25, 25, 5, -4, 5, -17, -2, -2,
+	14, -5, -6, -9, 6, 9, 5, 22, 23, -11,
+	17, 7, 19, -4, -7, -5, 5, -8, 15, 10,
+	-7, 8, -10, -5, 20, -12, -13, 5, -5, 17,
+	-9, -6, 18, 8, 11, 20, 11, 14, -4, 5,
+	-10, -12, -5, 10, -19, -20, -21, 5, 17, 19,
+	18, 16, -21, 5, -19, -14, -15, 5, 21, 16,
+	-19, 18, 20, 11, 10, 5, -7, -14, -19, 10,
+	-19,
 }
-var yyDef = [...]int{
 
-	2, -2, 1, 9, 0, 3, 9, 10, 5, 6,
-	7, 8, 0, 4, 0, 11, 0, 14, 12, 0,
-	13, 0, 15,
+var yyDef = [...]int{
+	9, -2, 1, 2, 4, 5, 6, 10, 0, 37,
+	0, 9, 0, 0, 0, 11, 37, 3, 8, 7,
+	0, 38, 13, 14, 15, 16, 17, 18, 19, 20,
+	0, 0, 0, 12, 49, 22, 17, 24, 0, 0,
+	0, 39, 0, 42, 44, 0, 46, 0, 37, 0,
+	40, 0, 21, 41, 0, 45, 0, 0, 23, 0,
+	43, 47, 48, 0, 0, 26, 28, 30, 0, 0,
+	0, 0, 29, 30, 0, 0, 33, 0, 0, 0,
+	27, 31, 32, 0, 0, 0, 25, 34, 35, 0,
+	36,
 }
-var yyTok1 = [...]int{
 
+var yyTok1 = [...]int{
 	1, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 12, 3, 3, 3, 3, 3, 3,
+	17, 18, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 24,
+	3, 14, 3, 21, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 14,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 25,
 }
-var yyTok2 = [...]int{
 
+var yyTok2 = [...]int{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
-	13, 15,
+	13, 15, 16, 19, 20, 22, 23,
 }
+
 var yyTok3 = [...]int{
 	0,
 }
@@ -463,103 +537,304 @@ yydefault:
 
 	case 1:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parser.y:38
+//line parser.y:61
 		{
-			yyVAL.command = yyDollar[1].command
 			if l, ok := yylex.(*exprLexer); ok {
-				l.expr = yyVAL.command
+				l.stmts = yyDollar[1].stmts
 			}
 		}
 	case 2:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:69
+		{
+			yyVAL.stmts = nil
+			if yyDollar[1].stmt != nil {
+				yyVAL.stmts = []ast.Stmt{yyDollar[1].stmt}
+			}
+		}
+	case 3:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:76
+		{
+			yyVAL.stmts = yyDollar[1].stmts
+			if yyDollar[3].stmt != nil {
+				yyVAL.stmts = append(yyVAL.stmts, yyDollar[3].stmt)
+			}
+		}
+	case 4:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:85
+		{
+			// Avoid a non-nil interface holding a nil command.
+			yyVAL.stmt = nil
+			if yyDollar[1].command != nil {
+				yyVAL.stmt = yyDollar[1].command
+			}
+		}
+	case 5:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:93
+		{
+			yyVAL.stmt = yyDollar[1].pipe
+		}
+	case 6:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:97
+		{
+			yyVAL.stmt = yyDollar[1].def
+		}
+	case 7:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:103
+		{
+			yyVAL.pipe = &ast.Pipe{Commands: []*ast.Command{yyDollar[1].command, yyDollar[3].command}}
+		}
+	case 8:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:107
+		{
+			yyVAL.pipe = &ast.Pipe{Commands: append(yyDollar[1].pipe.Commands, yyDollar[3].command)}
+		}
+	case 9:
 		yyDollar = yyS[yypt-0 : yypt+1]
-		//line parser.y:46
+//line parser.y:112
 		{
 			yyVAL.command = nil
 		}
-	case 3:
+	case 11:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parser.y:51
+//line parser.y:119
 		{
 			yyVAL.command = &ast.Command{yyDollar[1].token, yyDollar[2].exprs}
 		}
-	case 4:
+	case 12:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parser.y:55
+//line parser.y:123
 		{
 			yyVAL.command = &ast.Command{
 				token.Token{Lit: "exec", Line: yyDollar[1].token.Line, Column: yyDollar[1].token.Column},
 				append([]ast.Expr{&ast.String{yyDollar[2].token.Lit}}, yyDollar[3].exprs...),
 			}
 		}
-	case 5:
+	case 13:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:132
+		{
+			yyVAL.expr = yyDollar[1].expr
+		}
+	case 14:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parser.y:64
+//line parser.y:136
+		{
+			yyVAL.expr = yyDollar[1].list
+		}
+	case 15:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:142
 		{
 			yyVAL.expr = &ast.String{yyDollar[1].token.Lit}
 		}
-	case 6:
+	case 16:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parser.y:68
+//line parser.y:146
 		{
 			yyVAL.expr = &ast.Int{yyDollar[1].token.Lit}
 		}
-	case 7:
+	case 17:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parser.y:72
+//line parser.y:150
 		{
 			yyVAL.expr = &ast.Ident{yyDollar[1].token.Lit}
 		}
-	case 8:
+	case 18:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parser.y:76
+//line parser.y:154
 		{
-			yyVAL.expr = yyDollar[1].list
+			yyVAL.expr = &ast.Bool{Value: true}
 		}
-	case 9:
+	case 19:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:158
+		{
+			yyVAL.expr = &ast.Bool{Value: false}
+		}
+	case 20:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:162
+		{
+			yyVAL.expr = yyDollar[1].record
+		}
+	case 21:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:166
+		{
+			yyVAL.expr = yyDollar[2].expr
+		}
+	case 22:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:172
+		{
+			yyVAL.expr = yyDollar[1].expr
+		}
+	case 23:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:176
+		{
+			yyVAL.expr = &ast.App{Func: yyDollar[1].token, Args: append([]ast.Expr{yyDollar[2].expr}, yyDollar[3].exprs...)}
+		}
+	case 24:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:180
+		{
+			yyVAL.expr = yyDollar[1].expr
+		}
+	case 25:
+		yyDollar = yyS[yypt-8 : yypt+1]
+//line parser.y:186
+		{
+			yyVAL.expr = &ast.Fn{Param: yyDollar[3].token, ParamType: yyDollar[5].typ, Body: yyDollar[8].expr}
+		}
+	case 26:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:192
+		{
+			yyVAL.typ = yyDollar[1].typ
+		}
+	case 27:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:196
+		{
+			yyVAL.typ = types.Func{Param: yyDollar[1].typ, Result: yyDollar[3].typ}
+		}
+	case 28:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:202
+		{
+			yyVAL.typ = yyDollar[1].typ
+		}
+	case 29:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line parser.y:206
+		{
+			yyVAL.typ = applyType(yylex, yyDollar[1].token, yyDollar[2].typ)
+		}
+	case 30:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:212
+		{
+			yyVAL.typ = basicType(yylex, yyDollar[1].token)
+		}
+	case 31:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:216
+		{
+			yyVAL.typ = yyDollar[2].typ
+		}
+	case 32:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:220
+		{
+			yyVAL.typ = types.Record{Fields: yyDollar[2].ftypes}
+		}
+	case 33:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:226
+		{
+			yyVAL.ftypes = []types.Field{yyDollar[1].ftype}
+		}
+	case 34:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:230
+		{
+			yyVAL.ftypes = append([]types.Field{yyDollar[1].ftype}, yyDollar[3].ftypes...)
+		}
+	case 35:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:236
+		{
+			yyVAL.ftype = types.Field{Name: yyDollar[1].token.Lit, Type: yyDollar[3].typ}
+		}
+	case 36:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line parser.y:240
+		{
+			yyVAL.ftype = types.Field{Name: yyDollar[2].token.Lit, Type: yyDollar[4].typ, Optional: true}
+		}
+	case 37:
 		yyDollar = yyS[yypt-0 : yypt+1]
-		//line parser.y:81
+//line parser.y:245
 		{
 			yyVAL.exprs = nil
 		}
-	case 10:
+	case 38:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parser.y:85
+//line parser.y:249
 		{
 			yyVAL.exprs = append(yyDollar[1].exprs, yyDollar[2].expr)
 		}
-	case 11:
+	case 39:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parser.y:91
+//line parser.y:255
 		{
 			yyVAL.list = &ast.Empty{}
 		}
-	case 12:
+	case 40:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parser.y:95
+//line parser.y:259
 		{
-			yyVAL.list = &ast.Cons{Head: yyDollar[1].token.Lit, Tail: yyDollar[3].list}
+			yyVAL.list = &ast.Cons{Head: yyDollar[1].expr, Tail: yyDollar[3].list}
 		}
-	case 13:
+	case 41:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parser.y:99
+//line parser.y:263
 		{
 			yyVAL.list = yyDollar[2].list
 		}
-	case 14:
+	case 42:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parser.y:105
+//line parser.y:269
 		{
-			yyVAL.list = &ast.Cons{Head: yyDollar[1].token.Lit, Tail: &ast.Empty{}}
+			yyVAL.list = &ast.Cons{Head: yyDollar[1].expr, Tail: &ast.Empty{}}
 		}
-	case 15:
+	case 43:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parser.y:109
+//line parser.y:273
 		{
-			yyVAL.list = &ast.Cons{Head: yyDollar[1].token.Lit, Tail: yyDollar[3].list}
+			yyVAL.list = &ast.Cons{Head: yyDollar[1].expr, Tail: yyDollar[3].list}
 		}
-	case 16:
+	case 44:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line parser.y:279
+		{
+			yyVAL.record = &ast.Record{}
+		}
+	case 45:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:283
+		{
+			yyVAL.record = &ast.Record{Fields: yyDollar[2].fields}
+		}
+	case 46:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.y:289
+		{
+			yyVAL.fields = []ast.RecordField{yyDollar[1].field}
+		}
+	case 47:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:293
+		{
+			yyVAL.fields = append([]ast.RecordField{yyDollar[1].field}, yyDollar[3].fields...)
+		}
+	case 48:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.y:299
+		{
+			yyVAL.field = ast.RecordField{Name: yyDollar[1].token, Value: yyDollar[3].expr}
+		}
+	case 49:
 		yyDollar = yyS[yypt-4 : yypt+1]
-		//line parser.y:115
+//line parser.y:305
 		{
 			yyVAL.def = &ast.Def{Name: yyDollar[2].token, Expr: yyDollar[4].expr}
 		}
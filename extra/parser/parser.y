@@ -5,6 +5,7 @@ package parser
 import (
         "github.com/elpinal/coco3/extra/ast"
         "github.com/elpinal/coco3/extra/token"
+        "github.com/elpinal/coco3/extra/types"
 )
 
 %}
@@ -16,13 +17,31 @@ import (
         expr    ast.Expr
         list    ast.List
         def     *ast.Def
+        stmt    ast.Stmt
+        stmts   []ast.Stmt
+        pipe    *ast.Pipe
+        typ     types.Type
+        record  *ast.Record
+        fields  []ast.RecordField
+        field   ast.RecordField
+        ftypes  []types.Field
+        ftype   types.Field
 }
 
-%type <command> top command
+%type <command> command command1
+%type <pipe> pipe
 %type <exprs> exprs
-%type <expr> expr
-%type <list> string_list sep_by_commas
+%type <expr> expr atom term fn
+%type <list> list sep_by_commas
+%type <record> record
+%type <fields> fields
+%type <field> field
+%type <ftypes> field_types
+%type <ftype> field_type
 %type <def> def
+%type <stmt> stmt
+%type <stmts> stmts
+%type <typ> type btype atype
 
 %token <token> ILLEGAL
 
@@ -30,23 +49,72 @@ import (
 %token <token> DEF
 %token <token> '='
 %token <token> FN
+%token <token> ARROW '(' ')'
+%token <token> LBRACE RBRACE '?'
+%token <token> TRUE FALSE
+%token <token> ';' '|'
 
 %%
 
 top:
-        command
+        stmts
         {
-                $$ = $1
                 if l, ok := yylex.(*exprLexer); ok {
-                        l.expr = $$
+                        l.stmts = $1
+                }
+        }
+
+stmts:
+        stmt
+        {
+                $$ = nil
+                if $1 != nil {
+                        $$ = []ast.Stmt{$1}
                 }
         }
+        | stmts ';' stmt
+        {
+                $$ = $1
+                if $3 != nil {
+                        $$ = append($$, $3)
+                }
+        }
+
+stmt:
+        command
+        {
+                // Avoid a non-nil interface holding a nil command.
+                $$ = nil
+                if $1 != nil {
+                        $$ = $1
+                }
+        }
+        | pipe
+        {
+                $$ = $1
+        }
+        | def
+        {
+                $$ = $1
+        }
+
+pipe:
+        command1 '|' command1
+        {
+                $$ = &ast.Pipe{Commands: []*ast.Command{$1, $3}}
+        }
+        | pipe '|' command1
+        {
+                $$ = &ast.Pipe{Commands: append($1.Commands, $3)}
+        }
 
 command:
         {
                 $$ = nil
         }
-        |
+        | command1
+
+command1:
         IDENT exprs
         {
                 $$ = &ast.Command{$1, $2}
@@ -60,6 +128,16 @@ command:
         }
 
 expr:
+        atom
+        {
+                $$ = $1
+        }
+        | list
+        {
+                $$ = $1
+        }
+
+atom:
         STRING
         {
                 $$ = &ast.String{$1.Lit}
@@ -72,10 +150,96 @@ expr:
         {
                 $$ = &ast.Ident{$1.Lit}
         }
-        | string_list
+        | TRUE
+        {
+                $$ = &ast.Bool{Value: true}
+        }
+        | FALSE
+        {
+                $$ = &ast.Bool{Value: false}
+        }
+        | record
         {
                 $$ = $1
         }
+        | '(' term ')'
+        {
+                $$ = $2
+        }
+
+term:
+        expr
+        {
+                $$ = $1
+        }
+        | IDENT expr exprs
+        {
+                $$ = &ast.App{Func: $1, Args: append([]ast.Expr{$2}, $3...)}
+        }
+        | fn
+        {
+                $$ = $1
+        }
+
+fn:
+        FN '(' IDENT COLON type ')' ARROW term
+        {
+                $$ = &ast.Fn{Param: $3, ParamType: $5, Body: $8}
+        }
+
+type:
+        btype
+        {
+                $$ = $1
+        }
+        | btype ARROW type
+        {
+                $$ = types.Func{Param: $1, Result: $3}
+        }
+
+btype:
+        atype
+        {
+                $$ = $1
+        }
+        | IDENT atype
+        {
+                $$ = applyType(yylex, $1, $2)
+        }
+
+atype:
+        IDENT
+        {
+                $$ = basicType(yylex, $1)
+        }
+        | '(' type ')'
+        {
+                $$ = $2
+        }
+        | LBRACE field_types RBRACE
+        {
+                $$ = types.Record{Fields: $2}
+        }
+
+field_types:
+        field_type
+        {
+                $$ = []types.Field{$1}
+        }
+        | field_type COMMA field_types
+        {
+                $$ = append([]types.Field{$1}, $3...)
+        }
+
+field_type:
+        IDENT COLON type
+        {
+                $$ = types.Field{Name: $1.Lit, Type: $3}
+        }
+        | '?' IDENT COLON type
+        {
+                $$ = types.Field{Name: $2.Lit, Type: $4, Optional: true}
+        }
 
 exprs:
         {
@@ -86,14 +250,14 @@ exprs:
                 $$ = append($1, $2)
         }
 
-string_list:
+list:
         LBRACK RBRACK
         {
                 $$ = &ast.Empty{}
         }
-        | STRING COLON string_list
+        | atom COLON list
         {
-                $$ = &ast.Cons{Head: $1.Lit, Tail: $3}
+                $$ = &ast.Cons{Head: $1, Tail: $3}
         }
         | LBRACK sep_by_commas RBRACK
         {
@@ -101,17 +265,43 @@ string_list:
         }
 
 sep_by_commas:
-        STRING
+        expr
+        {
+                $$ = &ast.Cons{Head: $1, Tail: &ast.Empty{}}
+        }
+        | expr COMMA sep_by_commas
+        {
+                $$ = &ast.Cons{Head: $1, Tail: $3}
+        }
+
+record:
+        LBRACE RBRACE
+        {
+                $$ = &ast.Record{}
+        }
+        | LBRACE fields RBRACE
         {
-                $$ = &ast.Cons{Head: $1.Lit, Tail: &ast.Empty{}}
+                $$ = &ast.Record{Fields: $2}
         }
-        | STRING COMMA sep_by_commas
+
+fields:
+        field
+        {
+                $$ = []ast.RecordField{$1}
+        }
+        | field COMMA fields
+        {
+                $$ = append([]ast.RecordField{$1}, $3...)
+        }
+
+field:
+        IDENT '=' expr
         {
-                $$ = &ast.Cons{Head: $1.Lit, Tail: $3}
+                $$ = ast.RecordField{Name: $1, Value: $3}
         }
 
 def:
-        DEF IDENT '=' expr
+        DEF IDENT '=' term
         {
                 $$ = &ast.Def{Name: $2, Expr: $4}
         }
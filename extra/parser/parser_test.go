@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/elpinal/coco3/extra/ast"
+	"github.com/elpinal/coco3/extra/token"
+	"github.com/elpinal/coco3/extra/types"
 )
 
 func TestParse(t *testing.T) {
@@ -24,9 +26,9 @@ func TestParse(t *testing.T) {
 			name: "a",
 			args: []ast.Expr{
 				&ast.Cons{
-					Head: "u",
+					Head: &ast.String{Lit: "u"},
 					Tail: &ast.Cons{
-						Head: "v",
+						Head: &ast.String{Lit: "v"},
 						Tail: &ast.Empty{},
 					},
 				},
@@ -37,9 +39,9 @@ func TestParse(t *testing.T) {
 			name: "a-b",
 			args: []ast.Expr{
 				&ast.Cons{
-					Head: "u",
+					Head: &ast.String{Lit: "u"},
 					Tail: &ast.Cons{
-						Head: "v",
+						Head: &ast.String{Lit: "v"},
 						Tail: &ast.Empty{},
 					},
 				},
@@ -50,7 +52,7 @@ func TestParse(t *testing.T) {
 			name: "a-b1-2190",
 			args: []ast.Expr{
 				&ast.Cons{
-					Head: "",
+					Head: &ast.String{Lit: ""},
 					Tail: &ast.Empty{},
 				},
 				&ast.Int{
@@ -82,7 +84,7 @@ func TestParse(t *testing.T) {
 			name: "exec",
 			args: []ast.Expr{
 				&ast.String{Lit: "cmd"},
-				&ast.Cons{Head: "arg", Tail: &ast.Empty{}},
+				&ast.Cons{Head: &ast.String{Lit: "arg"}, Tail: &ast.Empty{}},
 			},
 		},
 	}
@@ -122,6 +124,267 @@ func TestParseFail(t *testing.T) {
 	}
 }
 
+func TestParseStmt(t *testing.T) {
+	tests := []struct {
+		src  string
+		want ast.Stmt
+	}{
+		{
+			src:  "",
+			want: nil,
+		},
+		{
+			src: "def x = 'a'",
+			want: &ast.Def{
+				Name: token.Token{Lit: "x", Line: 1, Column: 5},
+				Expr: &ast.String{Lit: "a"},
+			},
+		},
+		{
+			src: "def xs = ['a', 'b']",
+			want: &ast.Def{
+				Name: token.Token{Lit: "xs", Line: 1, Column: 5},
+				Expr: &ast.Cons{Head: &ast.String{Lit: "a"}, Tail: &ast.Cons{Head: &ast.String{Lit: "b"}, Tail: &ast.Empty{}}},
+			},
+		},
+		{
+			src: "def gst = git status",
+			want: &ast.Def{
+				Name: token.Token{Lit: "gst", Line: 1, Column: 5},
+				Expr: &ast.App{
+					Func: token.Token{Lit: "git", Line: 1, Column: 11},
+					Args: []ast.Expr{&ast.Ident{Lit: "status"}},
+				},
+			},
+		},
+		{
+			src: "def f = fn (x : List String) -> fn (g : String -> (Int -> Ident) -> Command) -> g 'a'",
+			want: &ast.Def{
+				Name: token.Token{Lit: "f", Line: 1, Column: 5},
+				Expr: &ast.Fn{
+					Param:     token.Token{Lit: "x", Line: 1, Column: 13},
+					ParamType: types.StringList,
+					Body: &ast.Fn{
+						Param: token.Token{Lit: "g", Line: 1, Column: 37},
+						ParamType: types.Func{
+							Param: types.String,
+							Result: types.Func{
+								Param:  types.Func{Param: types.Int, Result: types.Ident},
+								Result: types.Command,
+							},
+						},
+						Body: &ast.App{
+							Func: token.Token{Lit: "g", Line: 1, Column: 81},
+							Args: []ast.Expr{&ast.String{Lit: "a"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			src: "a (b 'c') (fn (x : Int) -> x)",
+			want: &ast.Command{
+				Name: token.Token{Lit: "a", Line: 1, Column: 1},
+				Args: []ast.Expr{
+					&ast.App{
+						Func: token.Token{Lit: "b", Line: 1, Column: 4},
+						Args: []ast.Expr{&ast.String{Lit: "c"}},
+					},
+					&ast.Fn{
+						Param:     token.Token{Lit: "x", Line: 1, Column: 16},
+						ParamType: types.Int,
+						Body:      &ast.Ident{Lit: "x"},
+					},
+				},
+			},
+		},
+		{
+			src: "a x",
+			want: &ast.Command{
+				Name: token.Token{Lit: "a", Line: 1, Column: 1},
+				Args: []ast.Expr{&ast.Ident{Lit: "x"}},
+			},
+		},
+		{
+			src: "a true [1, 2] x : [] {n = 1, v = false} {}",
+			want: &ast.Command{
+				Name: token.Token{Lit: "a", Line: 1, Column: 1},
+				Args: []ast.Expr{
+					&ast.Bool{Value: true},
+					&ast.Cons{
+						Head: &ast.Int{Lit: "1"},
+						Tail: &ast.Cons{Head: &ast.Int{Lit: "2"}, Tail: &ast.Empty{}},
+					},
+					&ast.Cons{Head: &ast.Ident{Lit: "x"}, Tail: &ast.Empty{}},
+					&ast.Record{Fields: []ast.RecordField{
+						{Name: token.Token{Lit: "n", Line: 1, Column: 23}, Value: &ast.Int{Lit: "1"}},
+						{Name: token.Token{Lit: "v", Line: 1, Column: 30}, Value: &ast.Bool{Value: false}},
+					}},
+					&ast.Record{},
+				},
+			},
+		},
+		{
+			src: "a 'x' | b |\n  c []",
+			want: &ast.Pipe{Commands: []*ast.Command{
+				{
+					Name: token.Token{Lit: "a", Line: 1, Column: 1},
+					Args: []ast.Expr{&ast.String{Lit: "x"}},
+				},
+				{Name: token.Token{Lit: "b", Line: 1, Column: 9}},
+				{
+					Name: token.Token{Lit: "c", Line: 2, Column: 3},
+					Args: []ast.Expr{&ast.Empty{}},
+				},
+			}},
+		},
+		{
+			src: "def f = fn (r : {n : Int, ?xs : List a}) -> r",
+			want: &ast.Def{
+				Name: token.Token{Lit: "f", Line: 1, Column: 5},
+				Expr: &ast.Fn{
+					Param: token.Token{Lit: "r", Line: 1, Column: 13},
+					ParamType: types.Record{Fields: []types.Field{
+						{Name: "n", Type: types.Int},
+						{Name: "xs", Type: types.List{Elem: types.Var{Name: "a"}}, Optional: true},
+					}},
+					Body: &ast.Ident{Lit: "r"},
+				},
+			},
+		},
+		{
+			src: "def f = fn (x : List (List Bool)) -> fn (p : Path) -> x",
+			want: &ast.Def{
+				Name: token.Token{Lit: "f", Line: 1, Column: 5},
+				Expr: &ast.Fn{
+					Param:     token.Token{Lit: "x", Line: 1, Column: 13},
+					ParamType: types.List{Elem: types.List{Elem: types.Bool}},
+					Body: &ast.Fn{
+						Param:     token.Token{Lit: "p", Line: 1, Column: 42},
+						ParamType: types.Path,
+						Body:      &ast.Ident{Lit: "x"},
+					},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		got, err := ParseStmt([]byte(test.src))
+		if err != nil {
+			t.Fatalf("ParseStmt(%q): %v", test.src, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseStmt(%q) = %v; want %v", test.src, got, test.want)
+		}
+	}
+
+	for _, src := range []string{
+		"def x",
+		"def x =",
+		"def = 'a'",
+		"def 'x' = 'a'",
+		"a def",
+		"def f = fn x -> x",
+		"def f = fn (x : Foo) -> x",
+		"def f = fn (x : List Foo) -> x",
+		"def f = fn (x : String) ->",
+		"a (b",
+		"a - b",
+		"a {n}",
+		"a {n = 1,}",
+		"def f = fn (r : {n}) -> r",
+		"def f = fn (r : {?n : Int ?}) -> r",
+		"a |",
+		"| a",
+		"a | | b",
+		"a |; b",
+		"def x = 'a' | b",
+		"a | def x = 'b'",
+	} {
+		if _, err := ParseStmt([]byte(src)); err == nil {
+			t.Errorf("ParseStmt(%q): unexpectedly succeeded", src)
+		}
+	}
+	if _, err := Parse([]byte("def x = 'a'")); err == nil {
+		t.Error("Parse: a definition should not be parsed as a command")
+	}
+	if _, err := Parse([]byte("a | b")); err == nil {
+		t.Error("Parse: a pipe should not be parsed as a command")
+	}
+}
+
+func TestParseProgram(t *testing.T) {
+	src := "a 'x'; def y = [\n  'b',\n  'c'\n]\n\n;\nb y\ndef f = fn (z : Int) ->\n  z\n"
+	got, err := ParseProgram([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseProgram(%q): %v", src, err)
+	}
+	want := []ast.Stmt{
+		&ast.Command{
+			Name: token.Token{Lit: "a", Line: 1, Column: 1},
+			Args: []ast.Expr{&ast.String{Lit: "x"}},
+		},
+		&ast.Def{
+			Name: token.Token{Lit: "y", Line: 1, Column: 12},
+			Expr: &ast.Cons{
+				Head: &ast.String{Lit: "b"},
+				Tail: &ast.Cons{Head: &ast.String{Lit: "c"}, Tail: &ast.Empty{}},
+			},
+		},
+		&ast.Command{
+			Name: token.Token{Lit: "b", Line: 7, Column: 1},
+			Args: []ast.Expr{&ast.Ident{Lit: "y"}},
+		},
+		&ast.Def{
+			Name: token.Token{Lit: "f", Line: 8, Column: 5},
+			Expr: &ast.Fn{
+				Param:     token.Token{Lit: "z", Line: 8, Column: 13},
+				ParamType: types.Int,
+				Body:      &ast.Ident{Lit: "z"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseProgram(%q) = %v; want %v", src, got, want)
+	}
+
+	for _, src := range []string{"", "\n", " ; \n;"} {
+		got, err := ParseProgram([]byte(src))
+		if err != nil {
+			t.Errorf("ParseProgram(%q): %v", src, err)
+		}
+		if len(got) != 0 {
+			t.Errorf("ParseProgram(%q) = %v; want no statements", src, got)
+		}
+	}
+
+	tests := []struct {
+		src    string
+		line   uint
+		column uint
+	}{
+		{"a 'x'\nb '", 2, 3},
+		{"a\nb\nc (", 3, 4},
+		{"a\ndef x\nb", 2, 6},
+		{"a\nb [\n'c' 'd']", 3, 5},
+	}
+	for _, test := range tests {
+		_, err := ParseProgram([]byte(test.src))
+		if err == nil {
+			t.Errorf("ParseProgram(%q): unexpectedly succeeded", test.src)
+			continue
+		}
+		pe := err.(*ParseError)
+		if pe.Line != test.line || pe.Column != test.column {
+			t.Errorf("ParseProgram(%q): error at %d:%d, want %d:%d: %v", test.src, pe.Line, pe.Column, test.line, test.column, err)
+		}
+	}
+
+	if _, err := ParseStmt([]byte("a\nb")); err == nil {
+		t.Error("ParseStmt: two statements should not be parsed as one")
+	}
+}
+
 func match(s, q string) bool {
 	return strings.Contains(s, q)
 }
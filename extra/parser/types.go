@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"github.com/elpinal/coco3/extra/token"
+	"github.com/elpinal/coco3/extra/types"
+)
+
+// basicType returns the type named by tok, e.g. String.
+func basicType(yylex yyLexer, tok token.Token) types.Type {
+	switch tok.Lit {
+	case "String":
+		return types.String
+	case "Int":
+		return types.Int
+	case "Ident":
+		return types.Ident
+	case "Bool":
+		return types.Bool
+	case "Path":
+		return types.Path
+	case "Command":
+		return types.Command
+	}
+	if isTypeVar(tok.Lit) {
+		return types.Var{Name: tok.Lit}
+	}
+	typeError(yylex, tok, "unknown type: %s", tok.Lit)
+	return nil
+}
+
+// isTypeVar reports whether name is the name of a type variable, which
+// begins with a lower-case letter.
+func isTypeVar(name string) bool {
+	return 'a' <= name[0] && name[0] <= 'z'
+}
+
+// applyType returns the type constructor named by tok applied to t. The
+// only one is List.
+func applyType(yylex yyLexer, tok token.Token, t types.Type) types.Type {
+	if tok.Lit == "List" {
+		return types.List{Elem: t}
+	}
+	typeError(yylex, tok, "unknown type: %s %s", tok.Lit, types.Paren(t))
+	return nil
+}
+
+func typeError(yylex yyLexer, tok token.Token, format string, args ...interface{}) {
+	if l, ok := yylex.(*exprLexer); ok {
+		l.errorAt(tok, format, args...)
+	}
+}
@@ -2,6 +2,8 @@ package typed
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 
 	"github.com/jmoiron/sqlx"
 
@@ -11,21 +13,63 @@ import (
 
 type Command struct {
 	Params []types.Type
-	Fn     func([]ast.Expr, *sqlx.DB) error
+	Fn     func([]ast.Expr, IO, *sqlx.DB) error
 }
 
+// IO holds the streams a command reads from and writes to.
+type IO struct {
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
+}
+
+// Signature returns the parameters of c as a curried type, e.g.
+// "String -> List String". It returns nil if c has no parameters.
 func (c *Command) Signature() []byte {
 	if len(c.Params) == 0 {
 		return nil
 	}
 	var buf bytes.Buffer
-	buf.WriteString(c.Params[0].String())
-	if len(c.Params) == 1 {
-		return buf.Bytes()
-	}
+	buf.WriteString(types.Paren(c.Params[0]))
 	for _, t := range c.Params[1:] {
 		buf.Write([]byte(" -> "))
-		buf.WriteString(t.String())
+		buf.WriteString(types.Paren(t))
 	}
 	return buf.Bytes()
 }
+
+// Type returns the type of c: a curried function from the parameters to
+// types.Command.
+func (c *Command) Type() types.Type {
+	return funcType(c.Params, types.Command)
+}
+
+func funcType(params []types.Type, result types.Type) types.Type {
+	t := result
+	for i := len(params) - 1; i >= 0; i-- {
+		t = types.Func{Param: params[i], Result: t}
+	}
+	return t
+}
+
+// Value returns c as a value. Applying it to all the parameters results in
+// an Action which calls c.Fn with the arguments, the streams given to the
+// Action and db.
+func (c *Command) Value(db *sqlx.DB) ast.Expr {
+	return c.partial(nil, db)
+}
+
+func (c *Command) partial(args []ast.Expr, db *sqlx.DB) ast.Expr {
+	n := len(args)
+	if n == len(c.Params) {
+		return &Action{run: func(s IO) error { return c.Fn(args, s, db) }}
+	}
+	t := funcType(c.Params[n:], types.Command).(types.Func)
+	return &Func{T: t, apply: func(arg ast.Expr) (ast.Expr, error) {
+		if !types.Assignable(arg.Type(), c.Params[n]) {
+			return nil, fmt.Errorf("type mismatch: (%v) (type of %v) does not match with (%v) (expected type)", arg.Type(), arg, c.Params[n])
+		}
+		// Copy args so that partial applications can be shared.
+		return c.partial(append(args[:n:n], arg), db), nil
+	}}
+}
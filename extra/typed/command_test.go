@@ -1,8 +1,12 @@
 package typed
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/jmoiron/sqlx"
+
+	"github.com/elpinal/coco3/extra/ast"
 	"github.com/elpinal/coco3/extra/types"
 )
 
@@ -27,6 +31,19 @@ func TestSignature(t *testing.T) {
 			ts:   []types.Type{types.Int, types.String, types.StringList},
 			want: "Int -> String -> List String",
 		},
+		{
+			ts: []types.Type{
+				types.Func{Param: types.String, Result: types.Command},
+				types.StringList,
+			},
+			want: "(String -> Command) -> List String",
+		},
+		{
+			ts: []types.Type{
+				types.Func{Param: types.Func{Param: types.Int, Result: types.String}, Result: types.Func{Param: types.String, Result: types.Command}},
+			},
+			want: "((Int -> String) -> String -> Command)",
+		},
 	}
 	for i, test := range tests {
 		cmd := Command{Params: test.ts}
@@ -36,3 +53,46 @@ func TestSignature(t *testing.T) {
 		}
 	}
 }
+
+func TestValue(t *testing.T) {
+	var got []ast.Expr
+	cmd := Command{
+		Params: []types.Type{types.Ident, types.StringList},
+		Fn: func(args []ast.Expr, _ IO, _ *sqlx.DB) error {
+			got = args
+			return nil
+		},
+	}
+	if want := "Ident -> List String -> Command"; cmd.Type().String() != want {
+		t.Errorf("Type: got %q, want %q", cmd.Type(), want)
+	}
+	v, err := cmd.Value(nil).(*Func).Apply(&ast.Ident{Lit: "status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := v.(*Func)
+	if !ok {
+		t.Fatalf("partial application: got %v, want a function", v)
+	}
+	if want := types.StringList; f.T.Param != want || f.T.Result != types.Command {
+		t.Errorf("partial application: got type %v, want %v -> Command", f.T, want)
+	}
+	if _, err := f.Apply(&ast.String{Lit: "a"}); err == nil {
+		t.Error("Apply: want type mismatch error")
+	}
+
+	// The partial application can be applied more than once.
+	for _, lit := range []string{"a", "b"} {
+		v, err := f.Apply(&ast.Cons{Head: &ast.String{Lit: lit}, Tail: &ast.Empty{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.(*Action).Run(IO{}); err != nil {
+			t.Fatal(err)
+		}
+		want := []ast.Expr{&ast.Ident{Lit: "status"}, &ast.Cons{Head: &ast.String{Lit: lit}, Tail: &ast.Empty{}}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("args: got %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package typed
+
+import (
+	"github.com/elpinal/coco3/extra/ast"
+	"github.com/elpinal/coco3/extra/types"
+)
+
+// A Func is a function value, e.g. a command applied to some of its
+// arguments, or a lambda.
+type Func struct {
+	T     types.Func
+	apply func(ast.Expr) (ast.Expr, error)
+}
+
+// NewFunc returns a function value of type t, which is applied by apply.
+func NewFunc(t types.Func, apply func(ast.Expr) (ast.Expr, error)) *Func {
+	return &Func{T: t, apply: apply}
+}
+
+// Apply applies f to arg.
+func (f *Func) Apply(arg ast.Expr) (ast.Expr, error) {
+	return f.apply(arg)
+}
+
+func (_ *Func) Expr() {}
+
+func (f *Func) Type() types.Type {
+	return f.T
+}
+
+func (f *Func) String() string {
+	return "<" + f.T.String() + ">"
+}
+
+// An Action is a command applied to all its arguments. It runs when
+// evaluated as a statement.
+type Action struct {
+	run func(IO) error
+}
+
+// Run runs the command with the streams s.
+func (a *Action) Run(s IO) error {
+	return a.run(s)
+}
+
+func (_ *Action) Expr() {}
+
+func (_ *Action) Type() types.Type {
+	return types.Command
+}
+
+func (_ *Action) String() string {
+	return "<Command>"
+}
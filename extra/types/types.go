@@ -1,15 +1,41 @@
 package types
 
-type Type int
+import (
+	"bytes"
+	"fmt"
+)
+
+// A Type is the type of an expression of extra mode. Use Equal to compare
+// types, as some of them, e.g. records, cannot be compared with ==.
+type Type interface {
+	String() string
+	typ()
+}
+
+// A Basic is a type which has no components.
+type Basic int
 
 const (
-	String Type = iota + 1
+	String Basic = iota + 1
 	Int
 	Ident
-	StringList
+	Bool
+
+	// Path is the type of the name of an existing file. A string literal
+	// is a Path where a Path is expected if the file exists.
+	Path
+
+	// Command is the type of a command applied to all its arguments,
+	// which runs when evaluated as a statement.
+	Command
 )
 
-func (t Type) String() string {
+// StringList is the type of lists of strings.
+var StringList = List{Elem: String}
+
+func (_ Basic) typ() {}
+
+func (t Basic) String() string {
 	switch t {
 	case String:
 		return "String"
@@ -17,8 +43,283 @@ func (t Type) String() string {
 		return "Int"
 	case Ident:
 		return "Ident"
-	case StringList:
-		return "List String"
+	case Bool:
+		return "Bool"
+	case Path:
+		return "Path"
+	case Command:
+		return "Command"
 	}
 	panic("unreachable")
 }
+
+// A List is the type of lists whose elements have type Elem.
+type List struct {
+	Elem Type
+}
+
+func (_ List) typ() {}
+
+func (l List) String() string {
+	return "List " + parenArg(l.Elem)
+}
+
+// A Var is a type variable, e.g. a in List a, which stands for any type.
+type Var struct {
+	Name string
+}
+
+func (_ Var) typ() {}
+
+func (v Var) String() string {
+	return v.Name
+}
+
+// A Func is the type of functions from Param to Result. A command with
+// parameters is a curried function whose final result is Command.
+type Func struct {
+	Param  Type
+	Result Type
+}
+
+func (_ Func) typ() {}
+
+func (f Func) String() string {
+	return Paren(f.Param) + " -> " + f.Result.String()
+}
+
+// A Record is the type of records, which give the arguments of commands by
+// name. Optional fields may be omitted in the records of the type.
+type Record struct {
+	Fields []Field
+}
+
+type Field struct {
+	Name     string
+	Type     Type
+	Optional bool
+}
+
+func (_ Record) typ() {}
+
+func (r Record) String() string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range r.Fields {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if f.Optional {
+			buf.WriteByte('?')
+		}
+		buf.WriteString(f.Name)
+		buf.WriteString(" : ")
+		buf.WriteString(f.Type.String())
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// Field returns the field named name.
+func (r Record) Field(name string) (Field, bool) {
+	for _, f := range r.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Paren returns the string of t, which is parenthesized if t is a function
+// type so that it can be a parameter of a function type.
+func Paren(t Type) string {
+	if _, ok := t.(Func); ok {
+		return "(" + t.String() + ")"
+	}
+	return t.String()
+}
+
+// parenArg returns the string of t parenthesized if it has components, so
+// that it can be the argument of a type constructor, e.g. List.
+func parenArg(t Type) string {
+	switch t.(type) {
+	case Func, List:
+		return "(" + t.String() + ")"
+	}
+	return t.String()
+}
+
+// Equal reports whether t and u are the same type.
+func Equal(t, u Type) bool {
+	switch t := t.(type) {
+	case List:
+		l, ok := u.(List)
+		return ok && Equal(t.Elem, l.Elem)
+	case Func:
+		f, ok := u.(Func)
+		return ok && Equal(t.Param, f.Param) && Equal(t.Result, f.Result)
+	case Record:
+		r, ok := u.(Record)
+		if !ok || len(t.Fields) != len(r.Fields) {
+			return false
+		}
+		for _, f := range t.Fields {
+			g, ok := r.Field(f.Name)
+			if !ok || f.Optional != g.Optional || !Equal(f.Type, g.Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return t == u
+}
+
+// A Subst maps the names of type variables to types.
+type Subst map[string]Type
+
+// Apply returns t with the type variables replaced according to s.
+func (s Subst) Apply(t Type) Type {
+	switch t := t.(type) {
+	case Var:
+		if u, ok := s[t.Name]; ok {
+			return s.Apply(u)
+		}
+	case List:
+		return List{Elem: s.Apply(t.Elem)}
+	case Func:
+		return Func{Param: s.Apply(t.Param), Result: s.Apply(t.Result)}
+	case Record:
+		fs := make([]Field, len(t.Fields))
+		for i, f := range t.Fields {
+			fs[i] = Field{Name: f.Name, Type: s.Apply(f.Type), Optional: f.Optional}
+		}
+		return Record{Fields: fs}
+	}
+	return t
+}
+
+// Unify finds the substitution, which is added to s, under which a value of
+// type t can be given where want is expected. A record can omit the
+// optional fields of want.
+func Unify(t, want Type, s Subst) error {
+	t = s.Apply(t)
+	want = s.Apply(want)
+	if v, ok := want.(Var); ok {
+		return s.bind(v, t)
+	}
+	if v, ok := t.(Var); ok {
+		return s.bind(v, want)
+	}
+	switch w := want.(type) {
+	case List:
+		l, ok := t.(List)
+		if ok {
+			return Unify(l.Elem, w.Elem, s)
+		}
+	case Func:
+		f, ok := t.(Func)
+		if ok {
+			if err := Unify(w.Param, f.Param, s); err != nil {
+				return err
+			}
+			return Unify(f.Result, w.Result, s)
+		}
+	case Record:
+		r, ok := t.(Record)
+		if ok {
+			return unifyRecord(r, w, s)
+		}
+	case Basic:
+		if t == want {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot use %v as %v", t, want)
+}
+
+func unifyRecord(r, want Record, s Subst) error {
+	for _, f := range r.Fields {
+		if _, ok := want.Field(f.Name); !ok {
+			return fmt.Errorf("unknown field %s in %v", f.Name, want)
+		}
+	}
+	for _, w := range want.Fields {
+		f, ok := r.Field(w.Name)
+		if !ok {
+			if w.Optional {
+				continue
+			}
+			return fmt.Errorf("missing field %s of %v", w.Name, want)
+		}
+		if err := Unify(f.Type, w.Type, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Assignable reports whether a value of type t can be given where want is
+// expected. The type variables of t are distinct from those of want.
+func Assignable(t, want Type) bool {
+	s := make(Subst)
+	for _, name := range Vars(t) {
+		s[name] = Var{Name: name + "'"}
+	}
+	return Unify(s.Apply(t), want, make(Subst)) == nil
+}
+
+func (s Subst) bind(v Var, t Type) error {
+	if u, ok := t.(Var); ok && u.Name == v.Name {
+		return nil
+	}
+	if occurs(v.Name, t) {
+		return fmt.Errorf("cannot construct the infinite type %v = %v", v, t)
+	}
+	s[v.Name] = t
+	return nil
+}
+
+func occurs(name string, t Type) bool {
+	switch t := t.(type) {
+	case Var:
+		return t.Name == name
+	case List:
+		return occurs(name, t.Elem)
+	case Func:
+		return occurs(name, t.Param) || occurs(name, t.Result)
+	case Record:
+		for _, f := range t.Fields {
+			if occurs(name, f.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Vars returns the names of the type variables in t.
+func Vars(t Type) []string {
+	var names []string
+	seen := make(map[string]bool)
+	var walk func(Type)
+	walk = func(t Type) {
+		switch t := t.(type) {
+		case Var:
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				names = append(names, t.Name)
+			}
+		case List:
+			walk(t.Elem)
+		case Func:
+			walk(t.Param)
+			walk(t.Result)
+		case Record:
+			for _, f := range t.Fields {
+				walk(f.Type)
+			}
+		}
+	}
+	walk(t)
+	return names
+}
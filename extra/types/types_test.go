@@ -0,0 +1,66 @@
+package types
+
+import "testing"
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		t    Type
+		want string
+	}{
+		{StringList, "List String"},
+		{List{Elem: List{Elem: Var{Name: "a"}}}, "List (List a)"},
+		{Func{Param: Func{Param: Int, Result: Bool}, Result: Command}, "(Int -> Bool) -> Command"},
+		{List{Elem: Func{Param: Path, Result: Command}}, "List (Path -> Command)"},
+		{Record{Fields: []Field{{Name: "n", Type: Int}, {Name: "v", Type: Bool, Optional: true}}}, "{n : Int, ?v : Bool}"},
+	}
+	for _, test := range tests {
+		if got := test.t.String(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestUnify(t *testing.T) {
+	a := Var{Name: "a"}
+	b := Var{Name: "b"}
+	opts := Record{Fields: []Field{{Name: "n", Type: Int}, {Name: "v", Type: Bool, Optional: true}}}
+	tests := []struct {
+		t, want Type
+		ok      bool
+		a       Type // the type bound to a, if any
+	}{
+		{String, String, true, nil},
+		{String, Int, false, nil},
+		{StringList, List{Elem: a}, true, String},
+		{List{Elem: a}, StringList, true, String},
+		{List{Elem: Int}, StringList, false, nil},
+		{Func{Param: a, Result: a}, Func{Param: Int, Result: b}, true, Int},
+		{List{Elem: a}, a, false, nil},
+		{Record{Fields: []Field{{Name: "n", Type: Int}}}, opts, true, nil},
+		{Record{Fields: []Field{{Name: "n", Type: Int}, {Name: "v", Type: Bool}}}, opts, true, nil},
+		{Record{Fields: []Field{{Name: "v", Type: Bool}}}, opts, false, nil},
+		{Record{Fields: []Field{{Name: "n", Type: Int}, {Name: "x", Type: Int}}}, opts, false, nil},
+		{Record{Fields: []Field{{Name: "n", Type: a}}}, opts, true, Int},
+	}
+	for _, test := range tests {
+		s := make(Subst)
+		err := Unify(test.t, test.want, s)
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("Unify(%v, %v): got error %v, want success %v", test.t, test.want, err, test.ok)
+			continue
+		}
+		if test.a != nil && !Equal(s.Apply(a), test.a) {
+			t.Errorf("Unify(%v, %v): a = %v, want %v", test.t, test.want, s.Apply(a), test.a)
+		}
+	}
+}
+
+func TestAssignable(t *testing.T) {
+	a := Var{Name: "a"}
+	if !Assignable(List{Elem: a}, a) {
+		t.Error("the type variables of the value should be distinct")
+	}
+	if Assignable(Int, String) {
+		t.Error("Int should not be assignable to String")
+	}
+}
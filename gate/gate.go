@@ -11,6 +11,10 @@ import (
 
 type Gate interface {
 	Read() ([]rune, bool, error)
+
+	// SetIncomplete sets the function which reports whether the input
+	// needs more lines.
+	SetIncomplete(func([]rune) bool)
 }
 
 type gate struct {
@@ -35,6 +39,10 @@ func (g *gate) Read() ([]rune, bool, error) {
 	return b, false, nil
 }
 
+func (g *gate) SetIncomplete(f func([]rune) bool) {
+	g.e.SetIncomplete(f)
+}
+
 func (g *gate) clear() {
 	g.e.Clear()
 }
@@ -1,14 +1,23 @@
 module github.com/elpinal/coco3
 
+go 1.27.1
+
 require (
 	github.com/elpinal/color v0.0.0-20170628111250-833605195c73
 	github.com/elpinal/revim v0.0.0-20170803110924-6921333cf2d3
-	github.com/go-sql-driver/mysql v1.4.1 // indirect
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/mattn/go-runewidth v0.0.4
 	github.com/mattn/go-sqlite3 v1.10.0
 	github.com/pkg/errors v0.8.1
 	golang.org/x/crypto v0.0.0-20190228161510-8dd112bcdc25
+)
+
+require (
+	github.com/go-sql-driver/mysql v1.4.1 // indirect
+	github.com/golang/protobuf v1.2.0 // indirect
+	github.com/lib/pq v1.0.0 // indirect
+	golang.org/x/net v0.0.0-20180724234803-3673e40ba225 // indirect
 	golang.org/x/sys v0.0.0-20190303192550-c2f5717e611c // indirect
+	golang.org/x/text v0.3.0 // indirect
 	google.golang.org/appengine v1.4.0 // indirect
 )
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"github.com/elpinal/coco3/ast"
+	"github.com/elpinal/coco3/scanner"
 	"github.com/elpinal/coco3/token"
 )
 
@@ -28,3 +29,12 @@ func ParseFile(fset *token.FileSet, filename string, src []byte) (f *ast.File, e
 
 	return
 }
+
+// Incomplete reports whether src fails to parse only because it ends too
+// early, e.g. in parentheses, a block or a string literal, or after '|'.
+// Such a source can be completed by the following lines.
+func Incomplete(src []byte) bool {
+	_, err := ParseSrc(src)
+	list, ok := err.(scanner.ErrorList)
+	return ok && list.Incomplete()
+}
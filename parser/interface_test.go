@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/elpinal/coco3/scanner"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"echo a", false},
+		{"echo (a", true},
+		{"echo 'a", true},
+		{"echo a |", true},
+		{"echo a &&", true},
+		{"cat <<EOF", true},
+		{"cat <<EOF\na", true},
+		{"cat <<EOF\na\nEOF", false},
+		{"echo )", false},
+		{"| echo a", false},
+		{"echo a \\", true},
+		{"echo a\\", true},
+		{"echo a \\\nb", false},
+	}
+	for _, test := range tests {
+		if got := Incomplete([]byte(test.src)); got != test.want {
+			t.Errorf("Incomplete(%q) = %v, want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestIncompleteError(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"echo 'a", true},
+		{"cat <<EOF", true},
+		{"cat <<EOF\na", true},
+		{"echo a |", true},
+		{"if true {", true},
+		{"echo )", false},
+		{"echo ${A", false},
+		{"echo a \\", true},
+	}
+	for _, test := range tests {
+		_, err := ParseSrc([]byte(test.src))
+		list, ok := err.(scanner.ErrorList)
+		if !ok || len(list) == 0 {
+			t.Errorf("ParseSrc(%q): got %v, want scanner.ErrorList", test.src, err)
+			continue
+		}
+		if got := list[0].Incomplete; got != test.want {
+			t.Errorf("ParseSrc(%q): %q: Incomplete = %v, want %v", test.src, list[0].Msg, got, test.want)
+		}
+	}
+}
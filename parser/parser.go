@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"strings"
+
 	"github.com/elpinal/coco3/ast"
 	"github.com/elpinal/coco3/scanner"
 	"github.com/elpinal/coco3/token"
@@ -17,11 +19,20 @@ type parser struct {
 	tok token.Token // one token look-ahead
 	lit string      // token literal
 
+	substLev int  // nesting level of command substitutions
+	blockLev int  // nesting level of blocks
+	inCond   bool // whether parsing a condition of if or while
 }
 
 func (p *parser) init(fset *token.FileSet, filename string, src []byte) {
 	p.file = fset.AddFile(filename, -1, len(src))
-	eh := func(pos token.Position, msg string) { p.errors.Add(pos, msg) }
+	eh := func(pos token.Position, msg string, incomplete bool) {
+		if incomplete {
+			p.errors.AddIncomplete(pos, msg)
+		} else {
+			p.errors.Add(pos, msg)
+		}
+	}
 	p.scanner.Init(p.file, src, eh)
 
 	p.next()
@@ -41,6 +52,11 @@ func (p *parser) next() {
 
 func (p *parser) error(pos token.Pos, msg string) {
 	epos := p.file.Position(pos)
+	if epos.Offset >= p.file.Size() {
+		// The source ends where a token is expected.
+		p.errors.AddIncomplete(epos, msg)
+		return
+	}
 	p.errors.Add(epos, msg)
 }
 
@@ -74,6 +90,10 @@ func (p *parser) checkExpr(x ast.Expr) ast.Expr {
 	switch x.(type) {
 	case *ast.BadExpr:
 	case *ast.Ident:
+	case *ast.BasicLit:
+	case *ast.VarExpr:
+	case *ast.WordExpr:
+	case *ast.SubstExpr:
 	case *ast.ParenExpr:
 		panic("unreachable")
 	case *ast.UnaryExpr:
@@ -98,10 +118,33 @@ func (p *parser) safePos(pos token.Pos) (res token.Pos) {
 func (p *parser) parseUnary() ast.Expr {
 	pos, op := p.pos, p.tok
 	p.next()
+	switch op {
+	case token.ERRTOOUT:
+		return &ast.UnaryExpr{OpPos: pos, Op: op}
+	case token.HEREDOC:
+		return &ast.UnaryExpr{OpPos: pos, Op: op, X: p.parseHereDoc()}
+	}
 	x := p.parseExpr()
 	return &ast.UnaryExpr{OpPos: pos, Op: op, X: p.checkExpr(x)}
 }
 
+func (p *parser) parseHereDoc() *ast.HereDoc {
+	x := &ast.HereDoc{DelimPos: p.pos, Delim: p.lit}
+	var delim string
+	switch p.tok {
+	case token.IDENT:
+		delim = p.lit
+	case token.STRING:
+		delim = strings.Replace(strings.Trim(p.lit, "'"), "''", "'", -1)
+	default:
+		p.errorExpected(p.pos, "here-document delimiter")
+		return x
+	}
+	x.Body = p.scanner.ScanHereDoc(delim)
+	p.next()
+	return x
+}
+
 func (p *parser) parseString() ast.Expr {
 	x := &ast.BasicLit{ValuePos: p.pos, Kind: p.tok, Value: p.lit}
 	p.next()
@@ -120,6 +163,58 @@ func (p *parser) parseIdent() *ast.Ident {
 	return &ast.Ident{NamePos: pos, Name: name}
 }
 
+func (p *parser) parseVar() *ast.VarExpr {
+	x := &ast.VarExpr{Dollar: p.pos}
+	lit := p.lit[1:] // strip "$"
+	if len(lit) >= 2 && lit[0] == '{' && lit[len(lit)-1] == '}' {
+		lit = lit[1 : len(lit)-1]
+		x.Braced = true
+	}
+	x.Name = lit
+	p.next()
+	return x
+}
+
+func (p *parser) parseSubst() *ast.SubstExpr {
+	pos := p.pos
+	p.next()
+	p.substLev++
+	var stmts []ast.Stmt
+	for p.tok != token.RPAREN && p.tok != token.EOF {
+		stmts = append(stmts, p.parseLine())
+	}
+	p.substLev--
+	rparen := p.expect(token.RPAREN)
+	return &ast.SubstExpr{Dollar: pos, Stmts: stmts, Rparen: rparen}
+}
+
+func isWordPart(tok token.Token) bool {
+	return tok == token.IDENT || tok == token.VAR || tok == token.SUBST
+}
+
+// parseWord parses adjacent identifiers, variable references and command
+// substitutions as a word.
+func (p *parser) parseWord() ast.Expr {
+	var parts []ast.Expr
+	for {
+		switch p.tok {
+		case token.IDENT:
+			parts = append(parts, p.parseIdent())
+		case token.VAR:
+			parts = append(parts, p.parseVar())
+		case token.SUBST:
+			parts = append(parts, p.parseSubst())
+		}
+		if !isWordPart(p.tok) || p.pos != parts[len(parts)-1].End() {
+			break
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return &ast.WordExpr{Parts: parts}
+}
+
 func (p *parser) parseList() *ast.ParenExpr {
 	pos := p.pos
 	var list []ast.Expr
@@ -140,11 +235,12 @@ func (p *parser) parseExpr() ast.Expr {
 	switch p.tok {
 	case token.LPAREN:
 		return p.parseList()
-	case token.IDENT:
-		return p.parseIdent()
+	case token.IDENT, token.VAR, token.SUBST:
+		return p.parseWord()
 	case token.STRING:
 		return p.parseString()
-	case token.REDIRIN, token.REDIROUT:
+	}
+	if p.tok.IsRedirect() {
 		return p.parseUnary()
 	}
 	pos := p.pos
@@ -153,37 +249,292 @@ func (p *parser) parseExpr() ast.Expr {
 	return &ast.BadExpr{From: pos, To: p.pos}
 }
 
-func (p *parser) parseExec() (bool, *ast.ExecStmt) {
+func (p *parser) parseExec() *ast.ExecStmt {
 	var args []ast.Expr
-	for p.tok != token.SEMICOLON && p.tok != token.EOF && p.tok != token.PIPE {
-		args = append(args, p.parseExpr())
+	var redirs []*ast.UnaryExpr
+	for !p.atStmtEnd() && p.tok != token.PIPE && p.tok != token.LAND && p.tok != token.LOR {
+		x := p.parseExpr()
+		if r, ok := x.(*ast.UnaryExpr); ok {
+			redirs = append(redirs, r)
+			continue
+		}
+		args = append(args, x)
 	}
-	var hasPipe bool
-	if p.tok == token.PIPE {
-		hasPipe = true
+	return &ast.ExecStmt{Args: args, Redirs: redirs}
+}
+
+func (p *parser) atStmtEnd() bool {
+	switch p.tok {
+	case token.SEMICOLON, token.EOF, token.BG:
+		return true
+	case token.RPAREN:
+		return p.substLev > 0
+	case token.RBRACE:
+		return p.blockLev > 0
+	case token.LBRACE:
+		return p.inCond
 	}
-	p.next()
-	return hasPipe, &ast.ExecStmt{Args: args}
+	return false
 }
 
-func (p *parser) parseLine() ast.Stmt {
-	var execs []*ast.ExecStmt
-	for {
-		hasPipe, stmt := p.parseExec()
-		execs = append(execs, stmt)
-		if !hasPipe {
-			break
+func (p *parser) parsePipe() ast.Stmt {
+	execs := []*ast.ExecStmt{p.parseExec()}
+	for p.tok == token.PIPE {
+		if isEmpty(execs[len(execs)-1]) {
+			p.errorExpected(p.pos, "command before '|'")
 		}
+		p.next()
+		// A newline is allowed after '|'.
+		for p.tok == token.SEMICOLON && p.lit == "\n" {
+			p.next()
+		}
+		x := p.parseExec()
+		if isEmpty(x) {
+			p.errorExpected(p.pos, "command after '|'")
+		}
+		execs = append(execs, x)
 	}
-	switch len(execs) {
-	case 0:
-		return nil
-	case 1:
+	if len(execs) == 1 {
 		return execs[0]
 	}
+	p.checkPipeRedirs(execs)
 	return &ast.PipeStmt{Args: execs}
 }
 
+// checkPipeRedirs reports redirections which conflict with pipes: those of
+// standard input except in the first command, and those of standard output
+// except in the last command.
+func (p *parser) checkPipeRedirs(execs []*ast.ExecStmt) {
+	last := len(execs) - 1
+	for i, x := range execs {
+		for _, r := range x.Redirs {
+			switch r.Op {
+			case token.REDIRIN, token.HEREDOC, token.HERESTR:
+				if i > 0 {
+					p.error(r.Pos(), "input redirection conflicts with pipe")
+				}
+			case token.REDIROUT, token.APPENDOUT, token.REDIRALL:
+				if i < last {
+					p.error(r.Pos(), "output redirection conflicts with pipe")
+				}
+			}
+		}
+	}
+}
+
+// isKeyword reports whether the current token is the keyword kw, which is
+// recognized only at the beginning of a statement.
+func (p *parser) isKeyword(kw string) bool {
+	return p.tok == token.IDENT && p.lit == kw
+}
+
+func (p *parser) parseBlock() *ast.BlockStmt {
+	lbrace := p.expect(token.LBRACE)
+	inCond := p.inCond
+	p.inCond = false
+	p.blockLev++
+	var list []ast.Stmt
+	for p.tok != token.RBRACE && p.tok != token.EOF {
+		if x := p.parseLine(); !isEmpty(x) {
+			list = append(list, x)
+		}
+	}
+	p.blockLev--
+	p.inCond = inCond
+	rbrace := p.expect(token.RBRACE)
+	return &ast.BlockStmt{Lbrace: lbrace, List: list, Rbrace: rbrace}
+}
+
+func (p *parser) parseCond() ast.Stmt {
+	inCond := p.inCond
+	p.inCond = true
+	x := p.parseAndOr()
+	p.inCond = inCond
+	if isEmpty(x) {
+		p.errorExpected(p.pos, "condition")
+	}
+	return x
+}
+
+func (p *parser) parseIf() *ast.IfStmt {
+	pos := p.pos
+	p.next()
+	x := &ast.IfStmt{If: pos, Cond: p.parseCond(), Body: p.parseBlock()}
+	if p.isKeyword("else") {
+		p.next()
+		if p.isKeyword("if") {
+			x.Else = p.parseIf()
+		} else {
+			x.Else = p.parseBlock()
+		}
+	}
+	return x
+}
+
+func (p *parser) parseWhile() *ast.WhileStmt {
+	pos := p.pos
+	p.next()
+	return &ast.WhileStmt{While: pos, Cond: p.parseCond(), Body: p.parseBlock()}
+}
+
+func (p *parser) parseFor() *ast.ForStmt {
+	pos := p.pos
+	p.next()
+	v := p.parseIdent()
+	if !token.IsVarName(v.Name) {
+		p.error(v.Pos(), "invalid variable name: "+v.Name)
+	}
+	if p.isKeyword("in") {
+		p.next()
+	} else {
+		p.errorExpected(p.pos, "'in'")
+	}
+	var list ast.Expr
+	switch p.tok {
+	case token.LPAREN:
+		list = p.parseList()
+	case token.IDENT, token.VAR, token.SUBST:
+		// A word such as $xs, whose value is spread.
+		list = p.parseWord()
+	case token.STRING:
+		list = p.parseString()
+	default:
+		p.errorExpected(p.pos, "'(' or word")
+		list = &ast.BadExpr{From: p.pos, To: p.pos}
+	}
+	return &ast.ForStmt{For: pos, Var: v, List: list, Body: p.parseBlock()}
+}
+
+func (p *parser) parseFunc() *ast.FuncStmt {
+	pos := p.pos
+	p.next()
+	return &ast.FuncStmt{Func: pos, Name: p.parseIdent(), Body: p.parseBlock()}
+}
+
+// parseCompound parses an if, while, for or func statement, and the
+// following terminator.
+func (p *parser) parseCompound() ast.Stmt {
+	var x ast.Stmt
+	switch p.lit {
+	case "func":
+		x = p.parseFunc()
+	case "if":
+		x = p.parseIf()
+	case "while":
+		x = p.parseWhile()
+	case "for":
+		x = p.parseFor()
+	}
+	p.skipStmtEnd()
+	return x
+}
+
+// skipStmtEnd skips the terminator of a statement which cannot be followed by
+// operators such as '&&' or '&'.
+func (p *parser) skipStmtEnd() {
+	switch {
+	case p.tok == token.SEMICOLON:
+		p.next()
+	case p.atStmtEnd():
+	default:
+		p.errorExpected(p.pos, "';' or newline")
+		p.next()
+	}
+}
+
+// assignName returns the variable name of lit if it begins an assignment,
+// e.g. "x=a"; otherwise "".
+func assignName(lit string) string {
+	i := strings.IndexByte(lit, '=')
+	if i < 0 || !token.IsVarName(lit[:i]) {
+		return ""
+	}
+	return lit[:i]
+}
+
+func (p *parser) parseAssign() *ast.AssignStmt {
+	name := assignName(p.lit)
+	x := &ast.AssignStmt{Name: &ast.Ident{NamePos: p.pos, Name: name}}
+	// The value begins just after "=".
+	start := x.Name.End() + 1
+	if rest := p.lit[len(name)+1:]; rest != "" {
+		p.pos, p.lit = start, rest
+		x.Value = p.parseWord()
+	} else {
+		p.next()
+		if p.pos == start {
+			switch {
+			case p.tok == token.LPAREN:
+				x.Value = p.parseList()
+			case p.tok == token.STRING:
+				x.Value = p.parseString()
+			case isWordPart(p.tok):
+				x.Value = p.parseWord()
+			}
+		}
+	}
+	p.skipStmtEnd()
+	return x
+}
+
+func (p *parser) parseLine() ast.Stmt {
+	if p.isKeyword("if") || p.isKeyword("while") || p.isKeyword("for") || p.isKeyword("func") {
+		return p.parseCompound()
+	}
+	if p.tok == token.IDENT && assignName(p.lit) != "" {
+		return p.parseAssign()
+	}
+	x := p.parseAndOr()
+	if p.tok == token.BG {
+		switch {
+		case isEmpty(x):
+			p.errorExpected(p.pos, "command before '&'")
+		case !isSimple(x):
+			p.error(p.pos, "only a command or a pipe can be run in background")
+		}
+		x = &ast.BgStmt{X: x, Amp: p.pos}
+	}
+	if p.tok != token.RPAREN && p.tok != token.RBRACE {
+		p.next() // skip ';', newline or '&'
+	}
+	return x
+}
+
+func (p *parser) parseAndOr() ast.Stmt {
+	x := p.parsePipe()
+	for p.tok == token.LAND || p.tok == token.LOR {
+		pos, op := p.pos, p.tok
+		if isEmpty(x) {
+			p.errorExpected(pos, "command before '"+op.String()+"'")
+		}
+		p.next()
+		// A newline is allowed after the operator.
+		for p.tok == token.SEMICOLON && p.lit == "\n" {
+			p.next()
+		}
+		y := p.parsePipe()
+		if isEmpty(y) {
+			p.errorExpected(p.pos, "command after '"+op.String()+"'")
+		}
+		x = &ast.AndOrStmt{X: x, OpPos: pos, Op: op, Y: y}
+	}
+	return x
+}
+
+// isSimple reports whether s is a command or a pipe.
+func isSimple(s ast.Stmt) bool {
+	switch s.(type) {
+	case *ast.ExecStmt, *ast.PipeStmt:
+		return true
+	}
+	return false
+}
+
+func isEmpty(s ast.Stmt) bool {
+	x, ok := s.(*ast.ExecStmt)
+	return ok && len(x.Args) == 0 && len(x.Redirs) == 0
+}
+
 // ----------------------------------------------------------------------------
 // Source files
 
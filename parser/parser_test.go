@@ -0,0 +1,73 @@
+package parser
+
+import "testing"
+
+func TestParseError(t *testing.T) {
+	tests := []string{
+		// And-or lists
+		"&& echo a",
+		"echo a ||",
+		"echo a && ; echo b",
+
+		// Variables
+		"echo ${",
+		"echo ${A",
+		"echo ${}",
+		"echo ${1a}",
+
+		// Command substitutions
+		"echo $(echo a",
+		"echo )",
+		"echo $(echo a))",
+
+		// Control flow
+		"if true",
+		"if { echo a }",
+		"if true { echo a",
+		"if true\n{ echo a }",
+		"if true { echo a } else",
+		"if true { echo a } echo b",
+		"while true",
+		"for x (a b) { echo a }",
+		"for x in { echo a }",
+		"for x in a b { echo a }",
+		"for $x in (a) { echo a }",
+		"echo }",
+		"{ echo a }",
+
+		// Assignments
+		"x=a echo",
+		"x=(a b",
+		"x=a && echo",
+
+		// Pipes
+		"echo a |",
+		"| echo a",
+		"echo a | | cat",
+		"echo a |;cat",
+
+		// Jobs
+		"&",
+		"true && true &",
+		"echo a & &",
+
+		// Redirections conflicting with pipes
+		"echo a > f | cat",
+		"echo a >> f | cat",
+		"echo a &> f | cat",
+		"echo a | cat < f",
+		"echo a | cat > f | cat",
+
+		// Here-documents
+		"cat <<EOF",
+		"cat <<EOF\na\n",
+		"cat <<",
+		"echo a | cat <<EOF\nEOF",
+		"echo a | cat <<<a",
+	}
+	for _, src := range tests {
+		if _, err := ParseSrc([]byte(src)); err == nil {
+			t.Errorf("ParseSrc(%q): unexpectedly succeeded", src)
+		}
+	}
+}
@@ -10,6 +10,10 @@ import (
 type Error struct {
 	Pos token.Position
 	Msg string
+
+	// Incomplete is true if the error is caused by the source ending too
+	// early, e.g. in a string literal or after '|'.
+	Incomplete bool
 }
 
 func (e Error) Error() string {
@@ -24,7 +28,23 @@ func (e Error) Error() string {
 type ErrorList []*Error
 
 func (p *ErrorList) Add(pos token.Position, msg string) {
-	*p = append(*p, &Error{pos, msg})
+	*p = append(*p, &Error{Pos: pos, Msg: msg})
+}
+
+// AddIncomplete adds an error caused by the source ending too early.
+func (p *ErrorList) AddIncomplete(pos token.Position, msg string) {
+	*p = append(*p, &Error{Pos: pos, Msg: msg, Incomplete: true})
+}
+
+// Incomplete reports whether p has errors, all of which are caused by the
+// source ending too early.
+func (p ErrorList) Incomplete() bool {
+	for _, e := range p {
+		if !e.Incomplete {
+			return false
+		}
+	}
+	return len(p) > 0
 }
 
 // ErrorList implements the sort Interface.
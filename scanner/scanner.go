@@ -3,12 +3,16 @@ package scanner
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/elpinal/coco3/token"
 )
 
-type ErrorHandler func(pos token.Position, msg string)
+// An ErrorHandler is called for each error found by the scanner. incomplete
+// reports whether the error is caused by the source ending too early, e.g.
+// in a string literal.
+type ErrorHandler func(pos token.Position, msg string, incomplete bool)
 
 type Scanner struct {
 	// immutable state
@@ -23,6 +27,7 @@ type Scanner struct {
 	rdOffset   int  // reading offset (position after current character)
 	lineOffset int  // current line offset
 	insertSemi bool // insert a semicolon before next newline
+	hereDocEnd int  // offset after the here-documents of the current line; or 0
 
 	// public state - ok to modify
 	ErrorCount int // number of errors encountered
@@ -38,6 +43,7 @@ func (s *Scanner) next() {
 		s.offset = s.rdOffset
 		if s.ch == '\n' {
 			s.lineOffset = s.offset
+			s.file.AddLine(s.offset)
 		}
 		r, w := rune(s.src[s.rdOffset]), 1
 		switch {
@@ -63,6 +69,15 @@ func (s *Scanner) next() {
 	}
 }
 
+// peek returns the byte following the most recently read character without
+// advancing the scanner. If the scanner is at EOF, peek returns 0.
+func (s *Scanner) peek() byte {
+	if s.rdOffset < len(s.src) {
+		return s.src[s.rdOffset]
+	}
+	return 0
+}
+
 func (s *Scanner) Init(file *token.File, src []byte, err ErrorHandler) {
 	// Explicitly initialize all fields since a scanner may be reused.
 	if file.Size() != len(src) {
@@ -78,6 +93,7 @@ func (s *Scanner) Init(file *token.File, src []byte, err ErrorHandler) {
 	s.rdOffset = 0
 	s.lineOffset = 0
 	s.insertSemi = false
+	s.hereDocEnd = 0
 	s.ErrorCount = 0
 
 	s.next()
@@ -87,16 +103,147 @@ func (s *Scanner) Init(file *token.File, src []byte, err ErrorHandler) {
 }
 
 func (s *Scanner) error(offs int, msg string) {
+	s.report(offs, msg, false)
+}
+
+// errorEOF reports an error caused by the source ending too early.
+func (s *Scanner) errorEOF(offs int, msg string) {
+	s.report(offs, msg, true)
+}
+
+func (s *Scanner) report(offs int, msg string, incomplete bool) {
 	if s.err != nil {
-		s.err(s.file.Position(s.file.Pos(offs)), msg)
+		s.err(s.file.Position(s.file.Pos(offs)), msg, incomplete)
 	}
 	s.ErrorCount++
 }
 
 func (s *Scanner) scanIdentifier() string {
 	offs := s.offset
-	for s.ch != ' ' && s.ch != '\t' && s.ch != '\n' && s.ch != -1 && s.ch != ';' && s.ch != '(' && s.ch != ')' {
+	for s.ch != ' ' && s.ch != '\t' && s.ch != '\n' && s.ch != -1 && s.ch != ';' && s.ch != '(' && s.ch != ')' && !s.atVariable() && !s.atSubst() && !s.atContinuation() {
+		s.next()
+		if s.ch == '\'' && isAssign(s.src[offs:s.offset]) {
+			// A quoted value of an assignment, e.g. x='a b'.
+			break
+		}
+	}
+	return string(s.src[offs:s.offset])
+}
+
+// isAssign reports whether lit is a variable name followed by "=".
+func isAssign(lit []byte) bool {
+	n := len(lit) - 1
+	return n > 0 && lit[n] == '=' && token.IsVarName(string(lit[:n]))
+}
+
+func isVarStart(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
+
+func isVarChar(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || '0' <= ch && ch <= '9'
+}
+
+// atVariable reports whether the current character begins a variable
+// reference.
+func (s *Scanner) atVariable() bool {
+	if s.ch != '$' {
+		return false
+	}
+	ch := s.peek()
+	return isVarStart(rune(ch)) || isSpecialVar(rune(ch)) || ch == '{'
+}
+
+// isSpecialVar reports whether ch is the name of a special variable: the
+// exit status (?), a positional parameter (1-9), the number of them (#), or
+// all of them (@).
+func isSpecialVar(ch rune) bool {
+	return ch == '?' || ch == '#' || ch == '@' || '1' <= ch && ch <= '9'
+}
+
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// atBrace reports whether the current character is a brace standing alone
+// as a word. Other braces, e.g. in {} or a{b}, are parts of identifiers.
+func (s *Scanner) atBrace() bool {
+	if s.ch != '{' && s.ch != '}' {
+		return false
+	}
+	switch s.peek() {
+	case 0, ' ', '\t', '\n', ';', ')':
+		return true
+	}
+	return false
+}
+
+// atComment reports whether the current character begins a comment, which
+// is a '#' at the beginning of a word and lasts until the end of the line.
+func (s *Scanner) atComment() bool {
+	if s.ch != '#' {
+		return false
+	}
+	if s.offset == 0 {
+		return true
+	}
+	switch s.src[s.offset-1] {
+	case ' ', '\t', '\n', ';':
+		return true
+	}
+	return false
+}
+
+// hasPrefix reports whether the source from the current character begins
+// with lit.
+func (s *Scanner) hasPrefix(lit string) bool {
+	return strings.HasPrefix(string(s.src[s.offset:]), lit)
+}
+
+// skip skips n ASCII characters.
+func (s *Scanner) skip(n int) {
+	for i := 0; i < n; i++ {
+		s.next()
+	}
+}
+
+// atSubst reports whether the current character begins a command
+// substitution.
+func (s *Scanner) atSubst() bool {
+	return s.ch == '$' && s.peek() == '('
+}
+
+// scanVariable scans a variable reference: $NAME, ${NAME} or $?.
+func (s *Scanner) scanVariable() string {
+	offs := s.offset
+	s.next() // '$'
+	switch {
+	case isSpecialVar(s.ch):
+		s.next()
+	case s.ch == '{':
+		s.next()
+		switch {
+		case isVarStart(s.ch):
+			for isVarChar(s.ch) {
+				s.next()
+			}
+		case isDigit(s.ch):
+			// A positional parameter, e.g. ${10}.
+			for isDigit(s.ch) {
+				s.next()
+			}
+		default:
+			s.error(s.offset, "invalid variable name")
+		}
+		if s.ch != '}' {
+			s.error(offs, "variable reference not terminated")
+			break
+		}
 		s.next()
+	default:
+		for isVarChar(s.ch) {
+			s.next()
+		}
 	}
 	return string(s.src[offs:s.offset])
 }
@@ -121,7 +268,7 @@ func (s *Scanner) scanString() string {
 	for {
 		ch := s.ch
 		if ch < 0 {
-			s.error(offs, "string literal not terminated")
+			s.errorEOF(offs, "string literal not terminated")
 			break
 		}
 		s.next()
@@ -144,18 +291,115 @@ func (s *Scanner) scanString() string {
 	return string(lit)
 }
 
+// ScanHereDoc reads the body of a here-document delimited by the line delim.
+// The body starts at the next line, or after the body of the preceding
+// here-document on the current line. It is skipped when the scanner reaches
+// the end of the current line.
+func (s *Scanner) ScanHereDoc(delim string) string {
+	start := s.hereDocEnd
+	if start == 0 {
+		i := strings.IndexByte(string(s.src[s.offset:]), '\n')
+		if i < 0 {
+			s.errorEOF(s.offset, "here-document body not found")
+			return ""
+		}
+		start = s.offset + i + 1
+	}
+	for off := start; off < len(s.src); {
+		next := len(s.src)
+		if i := strings.IndexByte(string(s.src[off:]), '\n'); i >= 0 {
+			next = off + i + 1
+		}
+		line := strings.TrimRight(string(s.src[off:next]), "\r\n")
+		if line == delim {
+			s.hereDocEnd = next
+			return string(s.src[start:off])
+		}
+		off = next
+	}
+	s.errorEOF(start, "here-document not terminated: expected "+delim)
+	s.hereDocEnd = len(s.src)
+	return string(s.src[start:])
+}
+
+// skipHereDocs moves the scanner to the end of the here-documents of the
+// previous line.
+func (s *Scanner) skipHereDocs() {
+	if s.hereDocEnd == 0 {
+		return
+	}
+	for i := s.offset; i < s.hereDocEnd; i++ {
+		if s.src[i] == '\n' {
+			s.file.AddLine(i + 1)
+		}
+	}
+	s.ch = '\n'
+	s.rdOffset = s.hereDocEnd
+	s.hereDocEnd = 0
+	s.next()
+}
+
+// skipWhitespace skips blanks and line continuations: a backslash before a
+// newline joins the lines.
 func (s *Scanner) skipWhitespace() {
-	for s.ch == ' ' || s.ch == '\t' {
-		s.next()
+	for {
+		switch {
+		case s.ch == ' ' || s.ch == '\t':
+			s.next()
+		case s.atContinuation():
+			if s.rdOffset >= len(s.src) {
+				s.errorEOF(s.offset, "line continuation at end of input")
+				s.next()
+				return
+			}
+			s.skip(2)
+		default:
+			return
+		}
 	}
 }
 
+// atContinuation reports whether the current character is a backslash at
+// the end of a line or of the source.
+func (s *Scanner) atContinuation() bool {
+	return s.ch == '\\' && (s.rdOffset >= len(s.src) || s.src[s.rdOffset] == '\n')
+}
+
 func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 	s.skipWhitespace()
+	if s.atComment() {
+		for s.ch != '\n' && s.ch != -1 {
+			s.next()
+		}
+	}
 
-	pos = token.Pos(s.offset)
+	pos = s.file.Pos(s.offset)
 
 	//insertSemi := false
+	if s.atVariable() {
+		return pos, token.VAR, s.scanVariable()
+	}
+	if s.atSubst() {
+		s.skip(2)
+		return pos, token.SUBST, ""
+	}
+	if s.atBrace() {
+		tok = token.LBRACE
+		if s.ch == '}' {
+			tok = token.RBRACE
+		}
+		s.next()
+		return pos, tok, ""
+	}
+	if s.ch == '2' {
+		for _, tok := range [...]token.Token{token.ERRTOOUT, token.APPENDERR, token.REDIRERR} {
+			if lit := tok.String(); s.hasPrefix(lit) {
+				s.skip(len(lit))
+				return pos, tok, ""
+			}
+		}
+	}
+
 	switch ch := s.ch; ch {
 	default:
 		lit = s.scanIdentifier()
@@ -170,6 +414,7 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 	case '\n':
 		s.next()             // always make progress
 		s.insertSemi = false // newline consumed
+		s.skipHereDocs()
 		return pos, token.SEMICOLON, "\n"
 	case '\'':
 		s.next()
@@ -182,18 +427,47 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 		s.next()
 		tok = token.RPAREN
 	case '<':
-		s.next()
-		tok = token.REDIRIN
+		switch {
+		case s.hasPrefix("<<<"):
+			s.skip(3)
+			tok = token.HERESTR
+		case s.hasPrefix("<<"):
+			s.skip(2)
+			tok = token.HEREDOC
+		default:
+			s.next()
+			tok = token.REDIRIN
+		}
 	case '>':
 		s.next()
 		tok = token.REDIROUT
+		if s.ch == '>' {
+			s.next()
+			tok = token.APPENDOUT
+		}
 	case '|':
 		s.next()
 		tok = token.PIPE
+		if s.ch == '|' {
+			s.next()
+			tok = token.LOR
+		}
 	case ';':
 		s.next()
 		tok = token.SEMICOLON
 		lit = ";"
+	case '&':
+		switch s.peek() {
+		case '&':
+			s.skip(2)
+			tok = token.LAND
+		case '>':
+			s.skip(2)
+			tok = token.REDIRALL
+		default:
+			s.next()
+			tok = token.BG
+		}
 	}
 
 	return
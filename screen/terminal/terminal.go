@@ -57,22 +57,29 @@ func (t *Terminal) draw(conf *config.Config, inCommandline bool, s []rune, pos i
 		}
 	}
 	count := strings.Count(prompt, "\n")
+	// The buffer may have newlines when the input continues over lines.
+	lines := countNewlines(s)
+	var cur int // the line of the cursor in s
 	if inCommandline {
-		count++
+		count += lines + 1
+	} else {
+		cur = countNewlines(s[:pos])
+		count += cur
 	}
 	t.lastCursorLine = count
 	t.w.WriteString("\r\033[J")
 	t.w.WriteString(strings.Replace(prompt, "\n", "\n\r", -1))
 	i := strings.LastIndex(prompt, "\n") + 1
 	promptWidth := runewidth.StringWidth(prompt[i:])
+	cont := conf.ContinuationPrompt
 	if hi == nil {
-		t.w.WriteString(string(s))
+		t.writeLines(s, cont)
 	} else {
-		t.w.WriteString(string(s[:hi.Left]))
+		t.writeLines(s[:hi.Left], cont)
 		t.w.WriteString("\033[7m")
-		t.w.WriteString(string(s[hi.Left:hi.Right]))
+		t.writeLines(s[hi.Left:hi.Right], cont)
 		t.w.WriteString("\033[0m")
-		t.w.WriteString(string(s[hi.Right:]))
+		t.writeLines(s[hi.Right:], cont)
 	}
 	if t.msg != "" {
 		t.w.WriteString("\n\r")
@@ -81,10 +88,18 @@ func (t *Terminal) draw(conf *config.Config, inCommandline bool, s []rune, pos i
 			t.w.WriteString("\033[A")
 		}
 	}
+	if !inCommandline && lines > cur {
+		t.w.WriteString("\033[")
+		t.w.WriteString(strconv.Itoa(lines - cur))
+		t.w.WriteString("A")
+	}
 	var drawPos int
-	if inCommandline {
+	switch {
+	case inCommandline:
 		drawPos = runewidth.StringWidth(t.msg[:pos])
-	} else {
+	case cur > 0:
+		drawPos = runewidth.StringWidth(cont) + runesWidth(s[lineStart(s, pos):pos])
+	default:
 		drawPos = promptWidth + runesWidth(s[:pos])
 	}
 	t.w.WriteString("\033[")
@@ -93,6 +108,30 @@ func (t *Terminal) draw(conf *config.Config, inCommandline bool, s []rune, pos i
 	t.w.Flush()
 }
 
+// writeLines writes s, beginning each line but the first with cont.
+func (t *Terminal) writeLines(s []rune, cont string) {
+	t.w.WriteString(strings.Replace(string(s), "\n", "\n\r"+cont, -1))
+}
+
+func countNewlines(s []rune) (n int) {
+	for _, r := range s {
+		if r == '\n' {
+			n++
+		}
+	}
+	return n
+}
+
+// lineStart returns the index of the beginning of the line containing pos.
+func lineStart(s []rune, pos int) int {
+	for i := pos; i > 0; i-- {
+		if s[i-1] == '\n' {
+			return i
+		}
+	}
+	return 0
+}
+
 func runesWidth(s []rune) (width int) {
 	for _, r := range s {
 		width += runewidth.RuneWidth(r)
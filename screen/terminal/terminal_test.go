@@ -19,6 +19,28 @@ func TestTerminal(t *testing.T) {
 	}
 }
 
+func TestTerminalMultiLine(t *testing.T) {
+	var buf bytes.Buffer
+	term := New(&buf)
+	conf := &config.Config{Prompt: "$ ", ContinuationPrompt: "> "}
+	term.Start(conf, false, []rune("echo (a\nbc"), 9, nil)
+	got := buf.String()
+	if want := "$ echo (a\n\r> bc\033[4G"; !strings.HasSuffix(got, want) {
+		t.Errorf("got %q, but should end with %q", got, want)
+	}
+
+	// The cursor moves to the first line.
+	buf.Reset()
+	term.Refresh(conf, false, []rune("echo (a\nbc"), 5, nil)
+	got = buf.String()
+	if want := "\033[1A\r"; !strings.HasPrefix(got, want) {
+		t.Errorf("got %q, but should begin with %q", got, want)
+	}
+	if want := "> bc\033[1A\033[8G"; !strings.HasSuffix(got, want) {
+		t.Errorf("got %q, but should end with %q", got, want)
+	}
+}
+
 func BenchmarkTerminal(b *testing.B) {
 	term := New(ioutil.Discard)
 	term.SetLastLine("-- last line --")
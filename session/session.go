@@ -0,0 +1,201 @@
+// Package session provides the state of a shell which lasts across the
+// evaluations of commands, e.g. the lines of interactive mode.
+package session
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/elpinal/coco3/eval"
+	"github.com/elpinal/coco3/extra"
+	eparser "github.com/elpinal/coco3/extra/parser"
+	"github.com/elpinal/coco3/extra/typed"
+	"github.com/elpinal/coco3/parser"
+	"github.com/elpinal/coco3/token"
+)
+
+// A Session holds the state shared by the commands evaluated in it: the
+// exit status of the last command, the jobs, the shell variables, the
+// functions, the aliases, the positional parameters, the source of the last
+// evaluation, and the typed commands and definitions of extra mode.
+//
+// The streams and the database may be changed between evaluations.
+type Session struct {
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
+
+	DB *sqlx.DB
+
+	// Report reports the errors of the commands which do not stop the
+	// evaluation, e.g. a failing command on the left of ||. If nil, they
+	// are written to Err.
+	Report func(error)
+
+	status int
+
+	jobs    *eval.JobTable
+	vars    *eval.Vars
+	funcs   *eval.Funcs
+	aliases *eval.Aliases
+
+	// args holds the positional parameters.
+	args []string
+
+	// fset holds the positions of the files evaluated by source and of the
+	// last evaluation, whose file and source are kept in last and src to
+	// report errors. The earlier evaluations are removed from fset.
+	fset *token.FileSet
+	last *token.File
+	src  []byte
+
+	extra extra.Env
+}
+
+// New returns a new session. Nil streams are replaced with empty ones.
+func New(in io.Reader, out, err io.Writer, db *sqlx.DB) *Session {
+	if out == nil {
+		out = ioutil.Discard
+	}
+	if err == nil {
+		err = ioutil.Discard
+	}
+	return &Session{
+		In:      in,
+		Out:     out,
+		Err:     err,
+		DB:      db,
+		jobs:    eval.NewJobTable(),
+		vars:    eval.NewVars(),
+		funcs:   eval.NewFuncs(),
+		aliases: eval.NewAliases(),
+		fset:    token.NewFileSet(),
+		extra:   extra.New(extra.Option{DB: db}),
+	}
+}
+
+// Status returns the exit status of the last command.
+func (s *Session) Status() int {
+	return s.status
+}
+
+// SetStatus sets the exit status of the last command, e.g. when a command
+// fails before evaluation.
+func (s *Session) SetStatus(status int) {
+	s.status = status
+}
+
+// Args returns the positional parameters.
+func (s *Session) Args() []string {
+	return s.args
+}
+
+// SetArgs sets the positional parameters.
+func (s *Session) SetArgs(args []string) {
+	s.args = args
+}
+
+// Jobs returns the table of the jobs started in s.
+func (s *Session) Jobs() *eval.JobTable {
+	return s.jobs
+}
+
+// Vars returns the shell variables.
+func (s *Session) Vars() *eval.Vars {
+	return s.vars
+}
+
+// Funcs returns the user-defined functions.
+func (s *Session) Funcs() *eval.Funcs {
+	return s.funcs
+}
+
+// Aliases returns the aliases.
+func (s *Session) Aliases() *eval.Aliases {
+	return s.aliases
+}
+
+// Extra returns the environment of extra mode, to which typed commands can
+// be bound.
+func (s *Session) Extra() *extra.Env {
+	return &s.extra
+}
+
+// FileSet returns the file set holding the positions of the last source
+// evaluated by Eval and the files evaluated by source.
+func (s *Session) FileSet() *token.FileSet {
+	return s.fset
+}
+
+// Source returns the source of f if it was evaluated by the last call to
+// Eval.
+func (s *Session) Source(f *token.File) ([]byte, bool) {
+	if f == nil || f != s.last {
+		return nil, false
+	}
+	return s.src, true
+}
+
+// Evaluator returns a new evaluator which runs against s. The exit status
+// of its last statement is not recorded in s; use Eval to do so.
+func (s *Session) Evaluator() *eval.Evaluator {
+	e := eval.New(s.In, s.Out, s.Err, s.DB)
+	e.SetStatus(s.status)
+	e.SetJobs(s.jobs)
+	e.SetVars(s.vars)
+	e.SetFuncs(s.funcs)
+	e.SetAliases(s.aliases)
+	e.SetArgs(s.args)
+	e.SetFileSet(s.fset)
+	e.SetErrorHandler(s.Report)
+	return e
+}
+
+// Eval parses src as a file named name and evaluates it. It reports whether
+// the exit built-in command was run; then Status returns the exit code.
+// Parse errors are returned as scanner.ErrorList. The source of the previous
+// call is forgotten, so its errors must be reported before calling Eval again.
+func (s *Session) Eval(name string, src []byte) (exit bool, err error) {
+	if s.last != nil {
+		// The errors of the last evaluation have been reported.
+		s.fset.RemoveFile(s.last)
+	}
+	base := s.fset.Base()
+	f, err := parser.ParseFile(s.fset, name, src)
+	s.last = s.fset.File(token.Pos(base))
+	s.src = src
+	if err != nil {
+		s.status = 1
+		return false, err
+	}
+	e := s.Evaluator()
+	err = e.Eval(f.Lines)
+	s.status = e.Status()
+	return e.Exited(), err
+}
+
+// EvalExtra parses src as a program of extra mode named name, a sequence of
+// commands, pipes and definitions, and evaluates the statements in order
+// until one fails. The commands use the streams of s, and definitions are
+// kept in s. Errors with positions are returned as *eparser.ParseError. The
+// exit status is that of the failing command, or 1 for parse and type errors.
+func (s *Session) EvalExtra(name string, src []byte) error {
+	stmts, err := eparser.ParseProgram(src)
+	if err == nil {
+		s.extra.DB = s.DB
+		s.extra.IO = typed.IO{In: s.In, Out: s.Out, Err: s.Err}
+		for _, stmt := range stmts {
+			if err = s.extra.Eval(stmt); err != nil {
+				break
+			}
+		}
+	}
+	s.status = extra.ExitStatus(err)
+	if pe, ok := err.(*eparser.ParseError); ok {
+		pe.Src = string(src)
+		pe.File = name
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+package session
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/elpinal/coco3/extra/ast"
+	"github.com/elpinal/coco3/extra/typed"
+	"github.com/elpinal/coco3/extra/types"
+	"github.com/elpinal/coco3/token"
+)
+
+func TestSession(t *testing.T) {
+	var out bytes.Buffer
+	s := New(nil, &out, ioutil.Discard, nil)
+	s.SetArgs([]string{"a", "b"})
+	for _, src := range []string{
+		"x=1",
+		"func f { echo $x $1 $# }",
+		"alias g 'f c'",
+		"g d",
+		"false",
+		"echo $? $@",
+	} {
+		exited, err := s.Eval("test", []byte(src))
+		if err != nil {
+			t.Fatalf("Eval(%q): %v", src, err)
+		}
+		if exited {
+			t.Fatalf("Eval(%q): unexpected exit", src)
+		}
+	}
+	if got, want := out.String(), "1 c 2\n1 a b\n"; got != want {
+		t.Errorf("output: got %q, want %q", got, want)
+	}
+	if s.Status() != 0 {
+		t.Errorf("status: got %v, want %v", s.Status(), 0)
+	}
+}
+
+func TestSessionExit(t *testing.T) {
+	s := New(nil, nil, nil, nil)
+	exited, err := s.Eval("test", []byte("exit 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exited {
+		t.Error("Eval: should exit")
+	}
+	if s.Status() != 3 {
+		t.Errorf("status: got %v, want %v", s.Status(), 3)
+	}
+}
+
+func TestSessionSource(t *testing.T) {
+	// Only the source of the last evaluation is kept.
+	s := New(nil, nil, nil, nil)
+	var prev *token.File
+	for _, src := range []string{"true", "echo a", "false"} {
+		pos := token.Pos(s.FileSet().Base())
+		if _, err := s.Eval("test", []byte(src)); err != nil {
+			t.Fatalf("Eval(%q): %v", src, err)
+		}
+		f := s.FileSet().File(pos)
+		if f == nil {
+			t.Fatalf("Eval(%q): no file", src)
+		}
+		if got, ok := s.Source(f); !ok || string(got) != src {
+			t.Errorf("Eval(%q): Source: got %q, %v", src, got, ok)
+		}
+		if prev != nil {
+			if s.FileSet().File(token.Pos(prev.Base())) != nil {
+				t.Errorf("Eval(%q): the previous file remains", src)
+			}
+			if _, ok := s.Source(prev); ok {
+				t.Errorf("Eval(%q): the previous source remains", src)
+			}
+		}
+		prev = f
+	}
+}
+
+func TestSessionParseError(t *testing.T) {
+	s := New(nil, nil, nil, nil)
+	if _, err := s.Eval("test", []byte("echo '")); err == nil {
+		t.Error("Eval: want error")
+	}
+	if s.Status() != 1 {
+		t.Errorf("status: got %v, want %v", s.Status(), 1)
+	}
+}
+
+func TestSessionExtra(t *testing.T) {
+	s := New(nil, nil, nil, nil)
+	var got []string
+	s.Extra().Bind("record", typed.Command{
+		Params: []types.Type{types.String},
+		Fn: func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+			got = append(got, args[0].(*ast.String).Lit)
+			return nil
+		},
+	})
+	for _, src := range []string{"record 'a'", "def x = 'b'", "record x"} {
+		if err := s.EvalExtra("test", []byte(src)); err != nil {
+			t.Fatalf("EvalExtra(%q): %v", src, err)
+		}
+	}
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("got %q, want %q", got, []string{"a", "b"})
+	}
+	if err := s.EvalExtra("test", []byte("nosuch")); err == nil {
+		t.Error("EvalExtra: want error")
+	}
+	if s.Status() != 1 {
+		t.Errorf("status: got %v, want %v", s.Status(), 1)
+	}
+}
+
+func TestSessionExtraStatus(t *testing.T) {
+	tests := []struct {
+		src    string
+		status int
+	}{
+		{"exec 'true' []", 0},
+		{"exec 'sh' ['-c', 'exit 3']", 3},
+		{"exec 'echo' ['a'] | exec 'sh' ['-c', 'exit 4']", 4},
+		{"exec 'coco3-no-such-command' []", 127},
+		{"exec 'true'", 1},
+		{"exec 'true' [", 1},
+	}
+	for _, test := range tests {
+		s := New(nil, nil, nil, nil)
+		s.EvalExtra("test", []byte(test.src))
+		if got := s.Status(); got != test.status {
+			t.Errorf("EvalExtra(%q): status: got %d, want %d", test.src, got, test.status)
+		}
+	}
+}
+
+func TestSessionExtraProgram(t *testing.T) {
+	s := New(nil, nil, nil, nil)
+	var got []string
+	s.Extra().Bind("record", typed.Command{
+		Params: []types.Type{types.String},
+		Fn: func(args []ast.Expr, _ typed.IO, _ *sqlx.DB) error {
+			got = append(got, args[0].(*ast.String).Lit)
+			return nil
+		},
+	})
+	src := "def x = 'a'\nrecord x; record 'b'\nnosuch x\nrecord 'c'\n"
+	err := s.EvalExtra("test.x", []byte(src))
+	if err == nil {
+		t.Fatal("EvalExtra: want error")
+	}
+	if want := "test.x:3:1: no such typed command: \"nosuch\""; err.Error() != want {
+		t.Errorf("EvalExtra: error: got %q, want %q", err, want)
+	}
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("got %q, want %q", got, []string{"a", "b"})
+	}
+	if s.Status() != 1 {
+		t.Errorf("status: got %v, want %v", s.Status(), 1)
+	}
+}
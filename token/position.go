@@ -111,6 +111,17 @@ func (f *File) Position(p Pos) (pos Position) {
 	return f.PositionFor(p, true)
 }
 
+// AddLine adds the line offset for a new line.
+// The line offset must be larger than the offset for the previous line
+// and smaller than the file size; otherwise the line offset is ignored.
+func (f *File) AddLine(offset int) {
+	f.set.mutex.Lock()
+	if i := len(f.lines); (i == 0 || f.lines[i-1] < offset) && offset < f.size {
+		f.lines = append(f.lines, offset)
+	}
+	f.set.mutex.Unlock()
+}
+
 type lineInfo struct {
 	// fields are exported to make them accessible to gob
 	Offset   int
@@ -145,6 +156,16 @@ func NewFileSet() *FileSet {
 	}
 }
 
+// Base returns the minimum base offset that must be provided to
+// AddFile when adding the next file.
+//
+func (s *FileSet) Base() int {
+	s.mutex.RLock()
+	b := s.base
+	s.mutex.RUnlock()
+	return b
+}
+
 func (s *FileSet) AddFile(filename string, base, size int) *File {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -167,6 +188,22 @@ func (s *FileSet) AddFile(filename string, base, size int) *File {
 	return f
 }
 
+// RemoveFile removes f from s, so that the positions in f are no longer
+// found in s. Removing a file which is not in s has no effect.
+func (s *FileSet) RemoveFile(f *File) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.last == f {
+		s.last = nil
+	}
+	for i, g := range s.files {
+		if g == f {
+			s.files = append(s.files[:i], s.files[i+1:]...)
+			return
+		}
+	}
+}
+
 func searchInts(a []int, x int) int {
 	// This function body is a manually inlined version of:
 	//
@@ -189,3 +226,45 @@ func searchInts(a []int, x int) int {
 	}
 	return i - 1
 }
+
+func (s *FileSet) file(p Pos) *File {
+	s.mutex.RLock()
+	// common case: p is in last file
+	if f := s.last; f != nil && f.base <= int(p) && int(p) <= f.base+f.size {
+		s.mutex.RUnlock()
+		return f
+	}
+	// p is not in last file - search all files
+	for _, f := range s.files {
+		if f.base <= int(p) && int(p) <= f.base+f.size {
+			s.mutex.RUnlock()
+			s.mutex.Lock()
+			s.last = f // race is ok - s.last is only a cache
+			s.mutex.Unlock()
+			return f
+		}
+	}
+	s.mutex.RUnlock()
+	return nil
+}
+
+// File returns the file that contains the position p.
+// If no such file is found (for instance for p == NoPos),
+// the result is nil.
+//
+func (s *FileSet) File(p Pos) (f *File) {
+	if p != NoPos {
+		f = s.file(p)
+	}
+	return
+}
+
+// Position converts a Pos p in the fileset into a Position value.
+// If p is not in any file of s, the result is the zero Position.
+//
+func (s *FileSet) Position(p Pos) (pos Position) {
+	if f := s.File(p); f != nil {
+		return f.position(p, true)
+	}
+	return
+}
@@ -14,17 +14,32 @@ const (
 	literal_beg
 	IDENT  // main
 	STRING // 'abc'
+	VAR    // $HOME
 	literal_end
 
 	operator_beg
 	LPAREN // (
 	RPAREN // )
-
-	REDIRIN  // <
-	REDIROUT // >
+	LBRACE // {
+	RBRACE // }
+	SUBST  // $(
+
+	REDIRIN   // <
+	REDIROUT  // >
+	APPENDOUT // >>
+	REDIRERR  // 2>
+	APPENDERR // 2>>
+	ERRTOOUT  // 2>&1
+	REDIRALL  // &>
+	HEREDOC   // <<
+	HERESTR   // <<<
 
 	PIPE // |
 
+	LAND // &&
+	LOR  // ||
+	BG   // &
+
 	SEMICOLON // ;
 	operator_end
 )
@@ -36,14 +51,29 @@ var tokens = [...]string{
 
 	IDENT:  "IDENT",
 	STRING: "STRING",
+	VAR:    "VAR",
 
 	LPAREN: "(",
 	RPAREN: ")",
-
-	REDIRIN:  "<",
-	REDIROUT: ">",
-
-	PIPE: ":",
+	LBRACE: "{",
+	RBRACE: "}",
+	SUBST:  "$(",
+
+	REDIRIN:   "<",
+	REDIROUT:  ">",
+	APPENDOUT: ">>",
+	REDIRERR:  "2>",
+	APPENDERR: "2>>",
+	ERRTOOUT:  "2>&1",
+	REDIRALL:  "&>",
+	HEREDOC:   "<<",
+	HERESTR:   "<<<",
+
+	PIPE: "|",
+
+	LAND: "&&",
+	LOR:  "||",
+	BG:   "&",
 
 	SEMICOLON: ";",
 }
@@ -62,3 +92,23 @@ func (tok Token) String() string {
 func (tok Token) IsLiteral() bool { return literal_beg < tok && tok < literal_end }
 
 func (tok Token) IsOperator() bool { return operator_beg < tok && tok < operator_end }
+
+// IsRedirect reports whether tok is a redirection operator.
+func (tok Token) IsRedirect() bool {
+	switch tok {
+	case REDIRIN, REDIROUT, APPENDOUT, REDIRERR, APPENDERR, ERRTOOUT, REDIRALL, HEREDOC, HERESTR:
+		return true
+	}
+	return false
+}
+
+// IsVarName reports whether name is a valid variable name: a letter or '_'
+// followed by letters, digits or '_'.
+func IsVarName(name string) bool {
+	for i, ch := range name {
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || i > 0 && '0' <= ch && ch <= '9') {
+			return false
+		}
+	}
+	return name != ""
+}